@@ -4,7 +4,10 @@ go 1.23.0
 
 toolchain go1.23.3
 
-require github.com/fatih/color v1.18.0
+require (
+	github.com/fatih/color v1.18.0
+	github.com/klauspost/compress v1.17.11
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.14 // indirect
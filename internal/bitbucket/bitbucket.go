@@ -0,0 +1,32 @@
+package bitbucket
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// authorizer returns the Authorizer for token. Tokens of the form
+// user:app-password are sent with basic authentication, anything else is
+// sent as a bearer token (repository, project or HTTP access tokens).
+func authorizer(token string) forge.Authorizer {
+	return func(req *http.Request) {
+		if user, password, ok := strings.Cut(token, ":"); ok {
+			req.SetBasicAuth(user, password)
+		} else if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// openFile starts downloading a raw file
+func openFile(url, token string, from resume.Point) (*resume.Response, error) {
+	req, err := forge.NewRequest("GET", url, authorizer(token))
+	if err != nil {
+		return nil, err
+	}
+
+	return resume.Get(resume.Client, req, from)
+}
@@ -0,0 +1,296 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// CloudAPIURL is the API base URL of bitbucket.org
+const CloudAPIURL = "https://api.bitbucket.org/2.0"
+
+// Client talks to the Bitbucket Cloud API
+type Client struct {
+	APIURL string
+	Token  string
+}
+
+type cloudEntry struct {
+	Path       string   `json:"path"`
+	Type       string   `json:"type"`
+	Size       int64    `json:"size"`
+	Attributes []string `json:"attributes"`
+}
+
+// Pages of Bitbucket Cloud listings. Next holds the full URL of the
+// following page and is empty on the last one.
+type srcPage struct {
+	Values []cloudEntry `json:"values"`
+	Next   string       `json:"next"`
+}
+
+type refPage struct {
+	Values []struct {
+		Name string `json:"name"`
+	} `json:"values"`
+	Next string `json:"next"`
+}
+
+type repositoryPage struct {
+	Values []cloudRepository `json:"values"`
+	Next   string            `json:"next"`
+}
+
+type cloudRepository struct {
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	Links       struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+// NewClient creates a client for the Bitbucket Cloud API
+func NewClient(apiURL, token string) *Client {
+	if apiURL == "" {
+		apiURL = CloudAPIURL
+	}
+	return &Client{APIURL: strings.TrimSuffix(apiURL, "/"), Token: token}
+}
+
+// Name returns the name of the hosting service
+func (c *Client) Name() string {
+	return "Bitbucket"
+}
+
+// ListDirectory lists the direct children of dirPath at ref
+func (c *Client) ListDirectory(workspace, repo, ref, dirPath string) ([]forge.Content, error) {
+	dirPath = forge.EscapePath(strings.Trim(dirPath, "/"))
+	if dirPath != "" {
+		dirPath += "/"
+	}
+	apiURL := fmt.Sprintf("%s/src/%s/%s?pagelen=100", c.repoURL(workspace, repo), url.PathEscape(ref), dirPath)
+
+	var contents []forge.Content
+	for apiURL != "" {
+		var page srcPage
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &page); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range page.Values {
+			contents = append(contents, c.entryContent(workspace, repo, ref, entry))
+		}
+		apiURL = page.Next
+	}
+
+	return contents, nil
+}
+
+// Stat describes the file or directory at filePath
+func (c *Client) Stat(workspace, repo, ref, filePath string) (forge.Content, error) {
+	filePath = strings.Trim(filePath, "/")
+	apiURL := fmt.Sprintf("%s/src/%s/%s?format=meta", c.repoURL(workspace, repo), url.PathEscape(ref), forge.EscapePath(filePath))
+
+	var entry cloudEntry
+	if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &entry); err != nil {
+		return forge.Content{}, err
+	}
+
+	return c.entryContent(workspace, repo, ref, entry), nil
+}
+
+// LFSEndpoint returns the Git LFS server of the repository. Access tokens
+// are sent as the password of the x-token-auth user.
+func (c *Client) LFSEndpoint(workspace, repo string) lfs.Endpoint {
+	endpoint := lfs.Endpoint{URL: fmt.Sprintf("https://bitbucket.org/%s/%s.git/info/lfs", workspace, repo)}
+	if user, password, ok := strings.Cut(c.Token, ":"); ok {
+		endpoint.Authorization = lfs.BasicAuth(user, password)
+	} else if c.Token != "" {
+		endpoint.Authorization = lfs.BasicAuth("x-token-auth", c.Token)
+	}
+	return endpoint
+}
+
+// OpenFile starts downloading the content of a listed file
+func (c *Client) OpenFile(content forge.Content, from resume.Point) (*resume.Response, error) {
+	return openFile(content.DownloadURL, c.Token, from)
+}
+
+// ResolveRef resolves a branch, tag or commit to a full commit SHA
+func (c *Client) ResolveRef(workspace, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/commit/%s", c.repoURL(workspace, repo), url.PathEscape(ref))
+
+	var commit struct {
+		Hash string `json:"hash"`
+	}
+	if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+
+	return commit.Hash, nil
+}
+
+// CommitTimes returns the time of the last commit at ref that changed each
+// of paths, asking for the history of one path at a time
+func (c *Client) CommitTimes(workspace, repo, ref string, paths []string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	for _, p := range paths {
+		apiURL := fmt.Sprintf("%s/commits/%s?pagelen=1", c.repoURL(workspace, repo), url.PathEscape(ref))
+		if p != "" {
+			apiURL += "&path=" + url.QueryEscape(p)
+		}
+
+		var commits struct {
+			Values []struct {
+				Date time.Time `json:"date"`
+			} `json:"values"`
+		}
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &commits); err != nil {
+			return nil, fmt.Errorf("failed to get history of %s: %w", p, err)
+		}
+		if len(commits.Values) > 0 {
+			times[p] = commits.Values[0].Date
+		}
+	}
+
+	return times, nil
+}
+
+// DefaultBranch returns the name of the repository's main branch
+func (c *Client) DefaultBranch(workspace, repo string) (string, error) {
+	var repository struct {
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	if _, err := forge.GetJSON("Bitbucket", c.repoURL(workspace, repo), authorizer(c.Token), &repository); err != nil {
+		return "", fmt.Errorf("failed to get repository metadata: %w", err)
+	}
+
+	return repository.MainBranch.Name, nil
+}
+
+// ListRefs lists the names of branches and tags containing prefix
+func (c *Client) ListRefs(workspace, repo, prefix string) ([]string, error) {
+	branches, err := c.listRefNames(workspace, repo, "branches", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.listRefNames(workspace, repo, "tags", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(branches, tags...), nil
+}
+
+// ListTags lists the names of all tags
+func (c *Client) ListTags(workspace, repo string) ([]string, error) {
+	return c.listRefNames(workspace, repo, "tags", "")
+}
+
+// listRefNames lists the names of the branches or tags containing filter
+func (c *Client) listRefNames(workspace, repo, kind, filter string) ([]string, error) {
+	apiURL := fmt.Sprintf("%s/refs/%s?pagelen=100", c.repoURL(workspace, repo), kind)
+	if filter != "" {
+		apiURL += "&q=" + url.QueryEscape(fmt.Sprintf(`name ~ "%s"`, filter))
+	}
+
+	var names []string
+	for apiURL != "" {
+		var page refPage
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &page); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+
+		for _, ref := range page.Values {
+			names = append(names, ref.Name)
+		}
+		apiURL = page.Next
+	}
+
+	return names, nil
+}
+
+// ListRepositories lists the repositories of a workspace
+func (c *Client) ListRepositories(workspace string) ([]forge.Repository, error) {
+	apiURL := fmt.Sprintf("%s/repositories/%s?pagelen=100", c.APIURL, url.PathEscape(workspace))
+
+	var repos []forge.Repository
+	for apiURL != "" {
+		var page repositoryPage
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &page); err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Values {
+			repo := forge.Repository{
+				Name:        r.Name,
+				FullName:    r.FullName,
+				Description: r.Description,
+				HTMLURL:     r.Links.HTML.Href,
+			}
+			for _, clone := range r.Links.Clone {
+				if clone.Name == "https" {
+					repo.CloneURL = clone.Href
+				}
+			}
+			repos = append(repos, repo)
+		}
+		apiURL = page.Next
+	}
+
+	return repos, nil
+}
+
+func (c *Client) repoURL(workspace, repo string) string {
+	return fmt.Sprintf("%s/repositories/%s/%s", c.APIURL, url.PathEscape(workspace), url.PathEscape(repo))
+}
+
+// entryContent converts a source listing entry to the Content representation
+func (c *Client) entryContent(workspace, repo, ref string, entry cloudEntry) forge.Content {
+	content := forge.Content{
+		Name: path.Base(entry.Path),
+		Path: entry.Path,
+		Size: entry.Size,
+	}
+
+	if entry.Type == "commit_directory" {
+		content.Type = "dir"
+		return content
+	}
+
+	content.Type = "file"
+	content.Mode = "100644"
+	for _, attribute := range entry.Attributes {
+		switch attribute {
+		case "link":
+			content.Type = "symlink"
+			content.Mode = "120000"
+		case "subrepository":
+			content.Type = "submodule"
+			content.Mode = "160000"
+		case "executable":
+			content.Mode = "100755"
+		}
+	}
+	if content.Type != "submodule" {
+		content.DownloadURL = fmt.Sprintf("%s/src/%s/%s", c.repoURL(workspace, repo), url.PathEscape(ref), forge.EscapePath(entry.Path))
+	}
+
+	return content
+}
@@ -0,0 +1,337 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// ServerClient talks to the REST API of a Bitbucket Server or Data Center
+// instance. Repositories are addressed by project key and repository slug;
+// personal repositories use the project key ~username.
+type ServerClient struct {
+	APIURL string
+	Token  string
+}
+
+// serverPage holds the paging fields shared by Bitbucket Server listings
+type serverPage struct {
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+type serverBrowse struct {
+	Children struct {
+		serverPage
+		Values []serverEntry `json:"values"`
+	} `json:"children"`
+}
+
+type serverEntry struct {
+	Path struct {
+		ToString string `json:"toString"`
+	} `json:"path"`
+	Type      string `json:"type"`
+	Size      int64  `json:"size"`
+	ContentID string `json:"contentId"`
+}
+
+type serverCommit struct {
+	ID string `json:"id"`
+	// CommitterTimestamp is in milliseconds since the Unix epoch
+	CommitterTimestamp int64 `json:"committerTimestamp"`
+}
+
+type serverCommits struct {
+	serverPage
+	Values []serverCommit `json:"values"`
+}
+
+// serverLastModified maps the names of a directory's children to the last
+// commit that changed them
+type serverLastModified struct {
+	Files map[string]serverCommit `json:"files"`
+}
+
+type serverRefs struct {
+	serverPage
+	Values []struct {
+		DisplayID string `json:"displayId"`
+	} `json:"values"`
+}
+
+type serverRepositories struct {
+	serverPage
+	Values []struct {
+		Name    string `json:"name"`
+		Slug    string `json:"slug"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Links struct {
+			Self []struct {
+				Href string `json:"href"`
+			} `json:"self"`
+			Clone []struct {
+				Name string `json:"name"`
+				Href string `json:"href"`
+			} `json:"clone"`
+		} `json:"links"`
+	} `json:"values"`
+}
+
+// NewServerClient creates a client for the Bitbucket Server API at apiURL,
+// e.g. https://bitbucket.example.com/rest/api/1.0
+func NewServerClient(apiURL, token string) *ServerClient {
+	return &ServerClient{APIURL: strings.TrimSuffix(apiURL, "/"), Token: token}
+}
+
+// Name returns the name of the hosting service
+func (c *ServerClient) Name() string {
+	return "Bitbucket Server"
+}
+
+// ListDirectory lists the direct children of dirPath at ref
+func (c *ServerClient) ListDirectory(project, repo, ref, dirPath string) ([]forge.Content, error) {
+	dirPath = strings.Trim(dirPath, "/")
+
+	var contents []forge.Content
+	for start := 0; ; {
+		apiURL := fmt.Sprintf("%s/browse/%s?at=%s&start=%d&limit=500", c.repoURL(project, repo), forge.EscapePath(dirPath), url.QueryEscape(ref), start)
+
+		var browse serverBrowse
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &browse); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range browse.Children.Values {
+			contents = append(contents, c.entryContent(project, repo, ref, dirPath, entry))
+		}
+
+		if browse.Children.IsLastPage {
+			return contents, nil
+		}
+		start = browse.Children.NextPageStart
+	}
+}
+
+// Stat describes the file or directory at filePath
+func (c *ServerClient) Stat(project, repo, ref, filePath string) (forge.Content, error) {
+	filePath = strings.Trim(filePath, "/")
+	apiURL := fmt.Sprintf("%s/browse/%s?at=%s&type=true", c.repoURL(project, repo), forge.EscapePath(filePath), url.QueryEscape(ref))
+
+	var entry serverEntry
+	if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &entry); err != nil {
+		return forge.Content{}, err
+	}
+
+	dirPath, name := path.Split(filePath)
+	entry.Path.ToString = name
+	return c.entryContent(project, repo, ref, strings.TrimSuffix(dirPath, "/"), entry), nil
+}
+
+// LFSEndpoint returns the Git LFS server of the repository, served next to
+// its clone URL below /scm
+func (c *ServerClient) LFSEndpoint(project, repo string) lfs.Endpoint {
+	webURL := strings.TrimSuffix(c.APIURL, "/rest/api/1.0")
+
+	endpoint := lfs.Endpoint{URL: fmt.Sprintf("%s/scm/%s/%s.git/info/lfs", webURL, strings.ToLower(project), repo)}
+	if user, password, ok := strings.Cut(c.Token, ":"); ok {
+		endpoint.Authorization = lfs.BasicAuth(user, password)
+	} else if c.Token != "" {
+		endpoint.Authorization = "Bearer " + c.Token
+	}
+	return endpoint
+}
+
+// OpenFile starts downloading the content of a listed file
+func (c *ServerClient) OpenFile(content forge.Content, from resume.Point) (*resume.Response, error) {
+	return openFile(content.DownloadURL, c.Token, from)
+}
+
+// ResolveRef resolves a branch, tag or commit to a full commit SHA
+func (c *ServerClient) ResolveRef(project, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/commits?until=%s&limit=1", c.repoURL(project, repo), url.QueryEscape(ref))
+
+	var commits serverCommits
+	if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &commits); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+	if len(commits.Values) == 0 {
+		return "", fmt.Errorf("failed to resolve ref %s: no commits found", ref)
+	}
+
+	return commits.Values[0].ID, nil
+}
+
+// CommitTimes returns the time of the last commit at ref that changed each
+// of paths. Paths are looked up one directory at a time.
+func (c *ServerClient) CommitTimes(project, repo, ref string, paths []string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+
+	dirs := make(map[string][]string)
+	for _, p := range paths {
+		if p == "" {
+			apiURL := fmt.Sprintf("%s/commits?until=%s&limit=1", c.repoURL(project, repo), url.QueryEscape(ref))
+
+			var commits serverCommits
+			if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &commits); err != nil {
+				return nil, fmt.Errorf("failed to get commit %s: %w", ref, err)
+			}
+			if len(commits.Values) > 0 {
+				times[p] = time.UnixMilli(commits.Values[0].CommitterTimestamp)
+			}
+			continue
+		}
+
+		dir := path.Dir(p)
+		if dir == "." {
+			dir = ""
+		}
+		dirs[dir] = append(dirs[dir], p)
+	}
+
+	for dir, files := range dirs {
+		apiURL := fmt.Sprintf("%s/last-modified/%s?at=%s", c.repoURL(project, repo), forge.EscapePath(dir), url.QueryEscape(ref))
+
+		var modified serverLastModified
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &modified); err != nil {
+			return nil, fmt.Errorf("failed to get history of %s: %w", dir, err)
+		}
+
+		for _, p := range files {
+			if commit, ok := modified.Files[path.Base(p)]; ok {
+				times[p] = time.UnixMilli(commit.CommitterTimestamp)
+			}
+		}
+	}
+
+	return times, nil
+}
+
+// DefaultBranch returns the name of the repository's default branch
+func (c *ServerClient) DefaultBranch(project, repo string) (string, error) {
+	var branch struct {
+		DisplayID string `json:"displayId"`
+	}
+	if _, err := forge.GetJSON("Bitbucket", c.repoURL(project, repo)+"/branches/default", authorizer(c.Token), &branch); err != nil {
+		return "", fmt.Errorf("failed to get default branch: %w", err)
+	}
+
+	return branch.DisplayID, nil
+}
+
+// ListRefs lists the names of branches and tags containing prefix
+func (c *ServerClient) ListRefs(project, repo, prefix string) ([]string, error) {
+	branches, err := c.listRefNames(project, repo, "branches", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.listRefNames(project, repo, "tags", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(branches, tags...), nil
+}
+
+// ListTags lists the names of all tags
+func (c *ServerClient) ListTags(project, repo string) ([]string, error) {
+	return c.listRefNames(project, repo, "tags", "")
+}
+
+// listRefNames lists the names of the branches or tags containing filter
+func (c *ServerClient) listRefNames(project, repo, kind, filter string) ([]string, error) {
+	var names []string
+	for start := 0; ; {
+		apiURL := fmt.Sprintf("%s/%s?filterText=%s&start=%d&limit=100", c.repoURL(project, repo), kind, url.QueryEscape(filter), start)
+
+		var page serverRefs
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &page); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+
+		for _, ref := range page.Values {
+			names = append(names, ref.DisplayID)
+		}
+
+		if page.IsLastPage {
+			return names, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// ListRepositories lists the repositories of a project
+func (c *ServerClient) ListRepositories(project string) ([]forge.Repository, error) {
+	var repos []forge.Repository
+	for start := 0; ; {
+		apiURL := fmt.Sprintf("%s/projects/%s/repos?start=%d&limit=100", c.APIURL, url.PathEscape(project), start)
+
+		var page serverRepositories
+		if _, err := forge.GetJSON("Bitbucket", apiURL, authorizer(c.Token), &page); err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Values {
+			repo := forge.Repository{
+				Name:     r.Slug,
+				FullName: r.Project.Key + "/" + r.Slug,
+			}
+			if len(r.Links.Self) > 0 {
+				repo.HTMLURL = r.Links.Self[0].Href
+			}
+			for _, clone := range r.Links.Clone {
+				if clone.Name == "http" || clone.Name == "https" {
+					repo.CloneURL = clone.Href
+				}
+			}
+			repos = append(repos, repo)
+		}
+
+		if page.IsLastPage {
+			return repos, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+func (c *ServerClient) repoURL(project, repo string) string {
+	return fmt.Sprintf("%s/projects/%s/repos/%s", c.APIURL, url.PathEscape(project), url.PathEscape(repo))
+}
+
+// entryContent converts a browse entry, whose path is relative to dirPath,
+// to the Content representation
+func (c *ServerClient) entryContent(project, repo, ref, dirPath string, entry serverEntry) forge.Content {
+	name := entry.Path.ToString
+	fullPath := name
+	if dirPath != "" {
+		fullPath = dirPath + "/" + name
+	}
+
+	content := forge.Content{
+		Name: name,
+		Path: fullPath,
+		Size: entry.Size,
+		SHA:  entry.ContentID,
+	}
+
+	switch entry.Type {
+	case "DIRECTORY":
+		content.Type = "dir"
+	case "SUBMODULE":
+		content.Type = "submodule"
+	default:
+		content.Type = "file"
+		content.DownloadURL = fmt.Sprintf("%s/raw/%s?at=%s", c.repoURL(project, repo), forge.EscapePath(fullPath), url.QueryEscape(ref))
+	}
+
+	return content
+}
@@ -0,0 +1,90 @@
+package bitbucket
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// IsCloudURL reports whether u points at bitbucket.org
+func IsCloudURL(u *url.URL) bool {
+	return u.Host == "bitbucket.org" || u.Host == "www.bitbucket.org"
+}
+
+// ParseCloudURL parses https://bitbucket.org/workspace/repo/src/ref/path URLs
+func ParseCloudURL(u *url.URL) (target forge.DownloadTarget, err error) {
+	target.Provider = forge.ProviderBitbucket
+	target.Host = forge.Host{APIURL: CloudAPIURL}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return forge.DownloadTarget{}, errors.New("invalid Bitbucket URL format, must be at least workspace/repo")
+	}
+
+	target.Owner = parts[0]
+	target.Repo = strings.TrimSuffix(parts[1], ".git")
+
+	if len(parts) >= 4 && parts[2] == "src" {
+		target.RefPath = strings.Join(parts[3:], "/")
+		target.Branch = parts[3]
+		if len(parts) > 4 {
+			target.DirPath = strings.Join(parts[4:], "/")
+		}
+	}
+
+	return target, nil
+}
+
+// serverRepo locates the projects/KEY/repos/SLUG or users/NAME/repos/SLUG
+// segments of a Bitbucket Server URL path and returns the index of "projects"
+// or "users", or -1 if the path has no such segments
+func serverRepo(parts []string) int {
+	for i := 0; i+3 < len(parts); i++ {
+		if (parts[i] == "projects" || parts[i] == "users") && parts[i+2] == "repos" {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsServerURL reports whether u points at a Bitbucket Server or Data Center repository
+func IsServerURL(u *url.URL) bool {
+	return serverRepo(strings.Split(strings.Trim(u.Path, "/"), "/")) >= 0
+}
+
+// ParseServerURL parses https://host/projects/KEY/repos/slug/browse/path?at=ref
+// URLs. Instances served below a context path keep it in their API URL, and
+// personal repositories under /users/NAME map to the project key ~NAME.
+func ParseServerURL(u *url.URL) (target forge.DownloadTarget, err error) {
+	target.Provider = forge.ProviderBitbucketServer
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	i := serverRepo(parts)
+	if i < 0 {
+		return forge.DownloadTarget{}, errors.New("invalid Bitbucket Server URL format, must contain projects/KEY/repos/slug")
+	}
+
+	contextPath := strings.Join(parts[:i], "/")
+	if contextPath != "" {
+		contextPath = "/" + contextPath
+	}
+	target.Host = forge.Host{APIURL: u.Scheme + "://" + u.Host + contextPath + "/rest/api/1.0"}
+
+	target.Owner = parts[i+1]
+	if parts[i] == "users" {
+		target.Owner = "~" + parts[i+1]
+	}
+	target.Repo = parts[i+3]
+
+	rest := parts[i+4:]
+	if len(rest) > 1 && rest[0] == "browse" {
+		target.DirPath = strings.Join(rest[1:], "/")
+	}
+	if at := u.Query().Get("at"); at != "" {
+		target.Branch = strings.TrimPrefix(at, "refs/heads/")
+	}
+
+	return target, nil
+}
@@ -1,22 +1,100 @@
 package config
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
 type AppFlags struct {
 	URL         string
 	Token       string
+	APIURL      string
+	RawURL      string
+	Traversal   string
 	Output      string
 	Recursive   bool
 	Concurrency int
 	Verbose     bool
 	ZipOutput   bool
+	Format      string
 	Preview     bool
 	Update      bool
 	ListFile    string
 	Retries     int
 	User        string
 	Interactive bool
+	Include     StringList
+	Exclude     StringList
+
+	KeepLFSPointers bool
+	Submodules      string
+	Dereference     bool
+	Mtime           string
+	Lock            bool
+	Locked          bool
+	Sync            bool
+	Yes             bool
+	Strategy        string
+}
+
+// StringList collects the values of a flag that may be repeated
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends a value each time the flag is given
+func (l *StringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// FileConfig holds the settings read from the user's config file
+type FileConfig struct {
+	APIURL string `json:"api_url"`
+	RawURL string `json:"raw_url"`
+	Token  string `json:"token"`
 }
 
 const (
 	AppName    = "gitdig"
 	AppVersion = "1.1.0"
 )
+
+// FilePath returns the location of the config file
+func FilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppName, "config.json"), nil
+}
+
+// LoadFile reads the config file. A missing file is not an error.
+func LoadFile() (FileConfig, error) {
+	var cfg FileConfig
+
+	path, err := FilePath()
+	if err != nil {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
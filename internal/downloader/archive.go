@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/display"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/source"
+)
+
+// Strategies for fetching the files of a directory
+const (
+	// StrategyFiles downloads every file with a request of its own
+	StrategyFiles = "files"
+	// StrategyArchive streams a tarball of the repository and extracts the
+	// listed files from it
+	StrategyArchive = "archive"
+	// StrategyAuto streams a tarball when more than archiveThreshold files
+	// are to be downloaded
+	StrategyAuto = "auto"
+)
+
+// archiveThreshold is the estimated number of files to download above which
+// StrategyAuto fetches an archive
+const archiveThreshold = 500
+
+// useArchive decides whether the files among entries are fetched from an
+// archive. During an update, files that exist locally with the listed size
+// are expected to be up to date and not counted.
+func (d *Downloader) useArchive(entries []forge.Content, dirPath, localDir string) bool {
+	if d.Strategy == StrategyArchive {
+		return true
+	}
+	if d.Strategy != StrategyAuto {
+		return false
+	}
+
+	files := 0
+	for _, content := range entries {
+		if content.Type != "file" {
+			continue
+		}
+		if d.Update && !d.ArchiveOutput {
+			localPath := filepath.Join(localDir, filepath.FromSlash(relativePath(content.Path, dirPath)))
+			if stat, err := os.Stat(localPath); err == nil && stat.Size() == content.Size {
+				continue
+			}
+		}
+		files++
+	}
+
+	if d.Verbose {
+		display.Info("About %d files to download, the archive threshold is %d\n", files, archiveThreshold)
+	}
+	return files > archiveThreshold
+}
+
+// downloadArchive extracts the files among entries from a tarball of the
+// repository and returns the entries left to download one at a time: files
+// the archive does not hold, such as those of submodules and Git LFS
+// objects, files that could not be extracted, and everything but files.
+func (d *Downloader) downloadArchive(target forge.DownloadTarget, entries []forge.Content, localDir string) []forge.Content {
+	opener, ok := d.source.(source.ArchiveOpener)
+	if !ok {
+		display.Warning("Warning: %s does not serve archives, downloading one file at a time\n", d.source.Name())
+		return entries
+	}
+
+	wanted := make(map[string]forge.Content)
+	for _, content := range entries {
+		if content.Type == "file" && d.repositoryOf(content.Path).path == "" {
+			wanted[content.Path] = content
+		}
+	}
+	if len(wanted) == 0 {
+		return entries
+	}
+
+	display.Info("Fetching %d files from an archive of %s/%s\n", len(wanted), target.Owner, target.Repo)
+
+	extracted, err := d.extractArchive(opener, target, wanted, localDir)
+	if err != nil {
+		display.Warning("Warning: Could not read the whole archive, downloading the remaining files one at a time: %v\n", err)
+	}
+
+	var remaining []forge.Content
+	for _, content := range entries {
+		if !extracted[content.Path] {
+			remaining = append(remaining, content)
+		}
+	}
+	return remaining
+}
+
+// extractArchive streams the tarball of target and saves the wanted files
+// found in it, keyed by their path in the repository. It returns the paths
+// of the files it took care of, even when reading the archive fails midway.
+func (d *Downloader) extractArchive(opener source.ArchiveOpener, target forge.DownloadTarget, wanted map[string]forge.Content, localDir string) (map[string]bool, error) {
+	extracted := make(map[string]bool)
+
+	body, err := opener.OpenArchive(target.Owner, target.Repo, target.Commit, target.DirPath)
+	if err != nil {
+		return extracted, fmt.Errorf("failed to download archive: %w", err)
+	}
+	defer body.Close()
+
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return extracted, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return extracted, nil
+		}
+		if err != nil {
+			return extracted, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Strip the top-level directory, e.g. owner-repo-sha/
+		_, p, _ := strings.Cut(header.Name, "/")
+		content, ok := wanted[p]
+		if !ok {
+			continue
+		}
+
+		// Content that differs from the listing, such as an LFS object stored
+		// in place of its pointer, is downloaded on its own
+		if content.Size > 0 && header.Size != content.Size {
+			continue
+		}
+
+		localPath := filepath.Join(localDir, filepath.FromSlash(relativePath(content.Path, target.DirPath)))
+		if d.extractFile(content, localPath, archive) {
+			extracted[content.Path] = true
+		}
+	}
+}
+
+// extractFile saves a file from the archive like downloadEntry saves a
+// download. It reports false when the file is left to be downloaded on its
+// own: when it is a Git LFS pointer, or when it cannot be saved or does not
+// match its blob ID.
+func (d *Downloader) extractFile(content forge.Content, filePath string, r io.Reader) bool {
+	exists, skip := d.checkExisting(content, filePath)
+	if skip {
+		return true
+	}
+
+	if !d.KeepLFSPointers {
+		body, _, pointer, err := peekPointer(r)
+		if err != nil || pointer != nil {
+			return false
+		}
+		r = body
+	}
+
+	var size int64
+	var err error
+	if d.ArchiveOutput {
+		size, err = d.addToArchive(content, content.Path, func(spool *os.File) (int64, error) {
+			return copyVerified(spool, 0, r, content)
+		})
+	} else {
+		if err = os.MkdirAll(filepath.Dir(filePath), 0755); err == nil {
+			size, err = saveFile(content, filePath, r)
+		}
+		if err == nil {
+			err = d.applyModTime(content, filePath)
+		}
+	}
+	if err != nil {
+		if d.Verbose {
+			display.Warning("Could not extract %s, downloading it separately: %v\n", content.Path, err)
+		}
+		return false
+	}
+
+	d.countDownload(content, filePath, size, exists)
+	return true
+}
+
+// saveFile writes a file from r to filePath through a temporary file,
+// verifying it against its blob ID
+func saveFile(content forge.Content, filePath string, r io.Reader) (int64, error) {
+	out, err := createTemp(filePath, 0666)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := copyVerified(out, 0, r, content)
+	if err == nil {
+		err = applyMode(content, out.Name())
+	}
+	if err != nil {
+		discardTemp(out)
+		return 0, err
+	}
+
+	if err := commitTemp(out, filePath); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Archive formats downloads can be saved in instead of a directory
+const (
+	FormatZip    = "zip"
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// Formats lists the archive formats
+var Formats = []string{FormatZip, FormatTar, FormatTarGz, FormatTarZst}
+
+// formatExtensions maps the file extensions archives are recognised by to
+// their format. The short forms of compressed tarballs are accepted too.
+var formatExtensions = []struct {
+	ext    string
+	format string
+}{
+	{".zip", FormatZip},
+	{".tar", FormatTar},
+	{".tar.gz", FormatTarGz},
+	{".tgz", FormatTarGz},
+	{".tar.zst", FormatTarZst},
+	{".tzst", FormatTarZst},
+}
+
+// ArchiveWriter adds downloaded entries to an archive. Implementations are
+// safe for concurrent use; entries are written one at a time. Entries
+// without a modification time are stamped with the time the archive was
+// created, like files on disk get the time they were downloaded.
+type ArchiveWriter interface {
+	// AddFile adds a file of size bytes, copying its content from r. A
+	// non-zero perm is stored as the entry's permission bits.
+	AddFile(r io.Reader, filePath string, size int64, perm os.FileMode, modified time.Time) error
+	// AddSymlink adds a symlink entry pointing to target
+	AddSymlink(target, filePath string, modified time.Time) error
+	// CreateDirEntry adds a directory entry
+	CreateDirEntry(dirPath string, modified time.Time) error
+	// Close finalizes the archive and moves it into place
+	Close() error
+}
+
+// NewArchiveWriter creates a writer for an archive of the given format. The
+// archive only appears at outputPath once it is closed.
+func NewArchiveWriter(outputPath, format string) (ArchiveWriter, error) {
+	switch format {
+	case FormatZip:
+		return NewZipWriter(outputPath, "")
+	case FormatTar, FormatTarGz, FormatTarZst:
+		return NewTarWriter(outputPath, format)
+	default:
+		return nil, fmt.Errorf("unknown archive format '%s'", format)
+	}
+}
+
+// FormatFromPath returns the archive format p is named for by its
+// extension, or an empty string when it has none
+func FormatFromPath(p string) string {
+	_, format := archiveExtension(p)
+	return format
+}
+
+// ArchivePath returns the path of an archive of the given format saved at
+// p, adding the format's extension unless p already has one of its
+// extensions
+func ArchivePath(p, format string) string {
+	if FormatFromPath(p) == format {
+		return p
+	}
+	return p + "." + format
+}
+
+// trimArchiveExtension returns p without its archive extension, if any
+func trimArchiveExtension(p string) string {
+	ext, _ := archiveExtension(p)
+	return p[:len(p)-len(ext)]
+}
+
+// archiveExtension returns the archive extension p ends with, in any case,
+// and the format it stands for
+func archiveExtension(p string) (ext, format string) {
+	lower := strings.ToLower(p)
+	for _, fe := range formatExtensions {
+		if strings.HasSuffix(lower, fe.ext) {
+			return fe.ext, fe.format
+		}
+	}
+	return "", ""
+}
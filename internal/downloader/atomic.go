@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/config"
+	"github.com/liagha/gitdig/internal/display"
+)
+
+// Files are written to a temporary file next to their destination, which is
+// renamed over it once complete, so that an interrupted run never leaves a
+// half-written file at the final path. Temporary files are hidden and named
+// after their destination, e.g. .gitdig-README.md-1234567.tmp.
+const (
+	tempPrefix = "." + config.AppName + "-"
+	tempSuffix = ".tmp"
+)
+
+// createTemp creates a temporary file in the directory of filePath, to be
+// moved into place with commitTemp. perm is subject to the umask, as with
+// os.OpenFile.
+func createTemp(filePath string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(filePath)
+
+	for range 100 {
+		name := filepath.Join(dir, tempPrefix+base+"-"+strconv.FormatUint(uint64(rand.Uint32()), 10)+tempSuffix)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("failed to create temporary file for %s", filePath)
+}
+
+// commitTemp flushes f to disk, closes it and renames it to filePath. f is
+// removed if any step fails.
+func commitTemp(f *os.File, filePath string) error {
+	err := f.Sync()
+	if err != nil {
+		err = fmt.Errorf("failed to sync file: %w", err)
+	}
+	if cerr := f.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("failed to close file: %w", cerr)
+	}
+	if err == nil {
+		if rerr := os.Rename(f.Name(), filePath); rerr != nil {
+			err = fmt.Errorf("failed to move file into place: %w", rerr)
+		}
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// discardTemp closes and removes a temporary file after a failed write
+func discardTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// writeFileAtomic writes data to filePath through a temporary file
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	f, err := createTemp(filePath, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		discardTemp(f)
+		return fmt.Errorf("failed to write file data: %w", err)
+	}
+
+	return commitTemp(f, filePath)
+}
+
+// isTempFile reports whether name is that of a temporary file
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, tempPrefix) && strings.HasSuffix(name, tempSuffix)
+}
+
+// removeTempFiles removes the temporary files that interrupted runs left in
+// dir, and below it when recursive is set. Unreadable directories are
+// skipped.
+func (d *Downloader) removeTempFiles(dir string, recursive bool) {
+	filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if p != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isTempFile(entry.Name()) {
+			if err := os.Remove(p); err == nil && d.Verbose {
+				display.Info("Removed leftover temporary file: %s\n", p)
+			}
+		}
+		return nil
+	})
+}
@@ -1,120 +1,451 @@
 package downloader
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/liagha/gitdig/internal/config"
 	"github.com/liagha/gitdig/internal/display"
-	"github.com/liagha/gitdig/internal/github"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/githash"
+	"github.com/liagha/gitdig/internal/glob"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+	"github.com/liagha/gitdig/internal/semver"
+	"github.com/liagha/gitdig/internal/source"
 )
 
+// ErrIntegrity reports a downloaded file whose content does not match the
+// blob ID or LFS pointer it was listed with
+var ErrIntegrity = errors.New("integrity error")
+
 type Stats struct {
 	Files    int
 	Dirs     int
 	Failures int
-	Bytes    int64
+	// Integrity counts the failures caused by content that did not match
+	// its listing
+	Integrity int
+	Skipped   int
+	Links     int
+	Bytes     int64
+	// New, Changed and Unchanged count the files of an update by the state
+	// of their local copy
+	New       int
+	Changed   int
+	Unchanged int
+	// Removed counts the local files deleted by a sync
+	Removed int
 	sync.Mutex
 }
 
+// Traversal modes for listing a directory
+const (
+	// TraversalContents lists one directory per Contents API request
+	TraversalContents = "contents"
+	// TraversalTree lists the whole tree with a single Git Trees API request
+	TraversalTree = "tree"
+	// TraversalAuto lists the whole tree where the provider can, which also
+	// reports file modes, and one directory at a time elsewhere
+	TraversalAuto = "auto"
+)
+
 type Downloader struct {
 	Token       string
+	Traversal   string
 	Recursive   bool
 	Concurrency int
 	Verbose     bool
-	ZipOutput   bool
 	Preview     bool
 	Update      bool
 	Retries     int
+	Filter      glob.Filter
+	// TokenHost is the GitHub host Token was given for; other hosts never
+	// receive it
+	TokenHost forge.Host
+	// ArchiveOutput saves downloads to an archive of the given Format, one
+	// of the Format constants, instead of a directory
+	ArchiveOutput bool
+	Format        string
+	// KeepLFSPointers saves Git LFS pointer files as they are instead of
+	// downloading the objects they point to
+	KeepLFSPointers bool
+	Submodules      string
+	// Dereference saves the files and directories symlinks point to
+	// instead of the links themselves
+	Dereference bool
+	// Timestamps is the timestamp mode, one of the Timestamps constants
+	Timestamps string
+	// WriteLock records each downloaded directory in a lockfile
+	WriteLock bool
+	// Locked downloads the commit recorded in the lockfile, failing if the
+	// upstream files no longer match it
+	Locked bool
+	// Sync deletes local files that no longer exist upstream, after asking
+	// for confirmation unless AssumeYes is set
+	Sync      bool
+	AssumeYes bool
+	// Strategy decides how the files of a directory are fetched, one of the
+	// Strategy constants
+	Strategy    string
 	Stats       Stats
 	wg          sync.WaitGroup
 	sem         chan struct{}
-	zipWriter   *ZipWriter
+	archive     ArchiveWriter
+	source      source.Provider
+	lfsEndpoint lfs.Endpoint
+	submodules  []submodule
+	// target is the resolved target being downloaded
+	target forge.DownloadTarget
+	// modTimes holds the modification times of the files being downloaded
+	modTimes map[string]time.Time
+	// hashes caches the blob IDs of local files during an update
+	hashes *hashCache
+	// lock is the lockfile being followed in locked mode
+	lock *lockFile
+	// unlisted holds the paths of the submodules of the current target that
+	// were left out of its listing; -sync leaves them alone
+	unlisted map[string]bool
+	// defaultBranches caches the default branch of each repository for the run
+	defaultBranches map[string]string
 }
 
-func New(token string, recursive bool, concurrency int, verbose bool, zipOutput bool, preview bool, update bool, retries int) *Downloader {
+func New(token string, traversal string, recursive bool, concurrency int, verbose bool, archiveOutput bool, preview bool, update bool, retries int) *Downloader {
 	return &Downloader{
-		Token:       token,
-		Recursive:   recursive,
-		Concurrency: concurrency,
-		Verbose:     verbose,
-		ZipOutput:   zipOutput,
-		Preview:     preview,
-		Update:      update,
-		Retries:     retries,
-		sem:         make(chan struct{}, concurrency),
+		Token:         token,
+		Traversal:     traversal,
+		Recursive:     recursive,
+		Concurrency:   concurrency,
+		Verbose:       verbose,
+		ArchiveOutput: archiveOutput,
+		Format:        FormatZip,
+		Preview:       preview,
+		Update:        update,
+		Retries:       retries,
+		sem:           make(chan struct{}, concurrency),
+
+		defaultBranches: make(map[string]string),
 	}
 }
 
-func (d *Downloader) DownloadRepository(owner, repo, branch, dirPath, localDir string) error {
+func (d *Downloader) DownloadRepository(target forge.DownloadTarget) error {
+	provider, err := source.New(target, source.Token(target, d.Token, d.TokenHost))
+	if err != nil {
+		return err
+	}
+	d.source = provider
+	d.lfsEndpoint = provider.LFSEndpoint(target.Owner, target.Repo)
+	d.submodules = nil
+
+	d.lock = nil
+	if d.Locked {
+		lock, err := readLockFile(d.lockPath(target))
+		if err != nil {
+			return err
+		}
+		target, err = d.pin(target, lock)
+		if err != nil {
+			return err
+		}
+		d.lock = &lock
+	} else {
+		target, err = d.resolveTarget(target)
+		if err != nil {
+			return err
+		}
+	}
+	d.target = target
+
+	if d.Update && !d.ArchiveOutput && !d.Preview {
+		d.hashes = loadHashCache()
+		defer func() {
+			if err := d.hashes.save(); err != nil {
+				display.Warning("Warning: %v\n", err)
+			}
+		}()
+	}
+
+	file, err := d.statFile(target)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		if !d.Filter.Allows(file.Path) {
+			d.Stats.Skipped++
+			display.Warning("Skipped %s: excluded by the include/exclude patterns\n", file.Path)
+			return nil
+		}
+		if d.Sync {
+			return fmt.Errorf("-sync mirrors directories, but %s is a file", file.Path)
+		}
+		if d.WriteLock {
+			display.Warning("Warning: Lockfiles are only written for directories, not for %s\n", file.Path)
+		}
+		return d.downloadSingleFile(target, *file)
+	}
+
+	owner, repo, dirPath, localDir := target.Owner, target.Repo, target.DirPath, target.LocalDir
+	branch := refLabel(target)
+
 	if d.Preview {
 		display.Bold("PREVIEW MODE: Showing what would be downloaded from %s/%s (branch: %s, path: %s)\n", owner, repo, branch, dirPath)
 		display.Info("Would save to: %s\n", localDir)
-		err := d.previewDirectory(owner, repo, branch, dirPath, "")
+		entries, err := d.listTarget(target)
 		if err != nil {
 			return err
 		}
-
-		display.BoldCyan("\nPreview Summary\n")
-		display.Info("Files: %d\n", d.Stats.Files)
-		display.Info("Directories: %d\n", d.Stats.Dirs)
+		d.previewDirectory(entries, dirPath)
+		if d.Sync {
+			plan, err := d.planSync(target, entries)
+			if err != nil {
+				return err
+			}
+			for _, rel := range plan.files {
+				display.Warning("Would remove: %s\n", filepath.Join(plan.root, filepath.FromSlash(rel)))
+			}
+		}
+		d.printPreviewSummary(target)
 
 		return nil
 	}
 
-	if !d.ZipOutput {
+	archivePath := ArchivePath(localDir, d.Format)
+
+	display.Bold("Downloading from %s/%s (branch: %s, path: %s)\n", owner, repo, branch, dirPath)
+	if d.ArchiveOutput {
+		display.Info("Saving to %s archive: %s\n", d.Format, archivePath)
+	} else {
+		display.Info("Saving to: %s\n", localDir)
+	}
+
+	startTime := time.Now()
+	failures := d.Stats.Failures
+	entries, err := d.listTarget(target)
+	if err != nil {
+		return err
+	}
+	if d.lock != nil {
+		display.Info("Upstream tree matches the lockfile (%d files)\n", len(d.lock.Files))
+	}
+
+	var plan *syncPlan
+	if d.Sync && !d.ArchiveOutput {
+		plan, err = d.planSync(target, entries)
+		if err != nil {
+			return err
+		}
+		if !d.confirmSync(plan) {
+			display.Warning("Keeping the files removed upstream\n")
+			plan = nil
+		}
+	}
+
+	if !d.ArchiveOutput {
 		if err := os.MkdirAll(localDir, 0755); err != nil {
 			return fmt.Errorf("failed to create output directory: %w", err)
 		}
+		d.removeTempFiles(localDir, true)
 	} else {
-		// Create zip file
-		zipPath := localDir
-		if !strings.HasSuffix(zipPath, ".zip") {
-			zipPath += ".zip"
-		}
-
-		// Create parent directory for zip file if needed
-		parentDir := filepath.Dir(zipPath)
+		// Create parent directory for the archive if needed
+		parentDir := filepath.Dir(archivePath)
 		if err := os.MkdirAll(parentDir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory for zip file: %w", err)
+			return fmt.Errorf("failed to create directory for archive: %w", err)
 		}
 
+		d.removeTempFiles(parentDir, false)
+
 		var err error
-		d.zipWriter, err = NewZipWriter(zipPath, "")
+		d.archive, err = NewArchiveWriter(archivePath, d.Format)
 		if err != nil {
-			return fmt.Errorf("failed to create zip archive: %w", err)
+			return fmt.Errorf("failed to create archive: %w", err)
 		}
-		defer d.zipWriter.Close()
+	}
+
+	d.loadTimestamps(entries)
 
-		display.Bold("Downloading from %s/%s (branch: %s, path: %s)\n", owner, repo, branch, dirPath)
-		display.Info("Saving to zip archive: %s\n", zipPath)
+	remaining := entries
+	if d.useArchive(entries, dirPath, localDir) {
+		remaining = d.downloadArchive(target, entries, localDir)
 	}
+	d.downloadEntries(remaining, dirPath, localDir)
 
-	if !d.ZipOutput {
-		display.Bold("Downloading from %s/%s (branch: %s, path: %s)\n", owner, repo, branch, dirPath)
-		display.Info("Saving to: %s\n", localDir)
+	d.wg.Wait()
+
+	if d.ArchiveOutput {
+		if err := d.archive.Close(); err != nil {
+			return err
+		}
 	}
 
-	startTime := time.Now()
-	err := d.downloadDirectory(owner, repo, branch, dirPath, localDir)
+	if plan != nil {
+		if d.Stats.Failures == failures {
+			d.applySync(plan)
+		} else {
+			display.Warning("Not removing files removed upstream, since some downloads failed\n")
+		}
+	}
+
+	// Only a complete download is worth locking
+	if d.WriteLock && d.lock == nil && d.Stats.Failures == failures {
+		lockPath := d.lockPath(target)
+		if err := writeLockFile(lockPath, d.newLockFile(target, entries)); err != nil {
+			return err
+		}
+		display.Info("Wrote lockfile: %s\n", lockPath)
+	}
+
+	return d.printSummary(target, startTime)
+}
+
+// listTarget lists the entries of a directory target, with submodules,
+// symlinks and filters applied. In locked mode the listing must match the
+// lockfile.
+func (d *Downloader) listTarget(target forge.DownloadTarget) ([]forge.Content, error) {
+	entries, err := d.listEntries(d.source, target.Owner, target.Repo, target.Commit, target.DirPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	d.unlisted = make(map[string]bool)
+	entries = d.expandSubmodules(target, d.source, "", entries, make(map[string]bool))
+	entries = d.resolveSymlinks(entries, target.DirPath)
+	entries = d.filterEntries(entries)
 
-	d.wg.Wait()
+	if d.lock != nil {
+		if err := d.lock.verify(entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// statFile returns the file the target points at, or nil when the target is
+// a directory
+func (d *Downloader) statFile(target forge.DownloadTarget) (*forge.Content, error) {
+	if strings.Trim(target.DirPath, "/") == "" {
+		return nil, nil
+	}
+
+	content, err := d.source.Stat(target.Owner, target.Repo, target.Commit, target.DirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path info: %w", err)
+	}
+	if content.Type != "file" {
+		return nil, nil
+	}
 
+	return &content, nil
+}
+
+// downloadSingleFile saves a target that points at a single file into the
+// output directory, or adds it to the archive
+func (d *Downloader) downloadSingleFile(target forge.DownloadTarget, file forge.Content) error {
+	branch := refLabel(target)
+	localPath := filepath.Join(target.OutputDir, file.Name)
+
+	if d.Preview {
+		display.Bold("PREVIEW MODE: Showing what would be downloaded from %s/%s (branch: %s, path: %s)\n", target.Owner, target.Repo, branch, file.Path)
+		display.Info("Would save to: %s\n", localPath)
+		display.Info("└── %s\n", file.Name)
+		d.Stats.Files++
+		d.printPreviewSummary(target)
+
+		return nil
+	}
+
+	if d.ArchiveOutput {
+		archivePath := ArchivePath(target.LocalDir, d.Format)
+		if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for archive: %w", err)
+		}
+
+		d.removeTempFiles(filepath.Dir(archivePath), false)
+
+		var err error
+		d.archive, err = NewArchiveWriter(archivePath, d.Format)
+		if err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
+		}
+
+		display.Bold("Downloading from %s/%s (branch: %s, path: %s)\n", target.Owner, target.Repo, branch, file.Path)
+		display.Info("Saving to %s archive: %s\n", d.Format, archivePath)
+	} else {
+		d.removeTempFiles(filepath.Dir(localPath), false)
+
+		display.Bold("Downloading from %s/%s (branch: %s, path: %s)\n", target.Owner, target.Repo, branch, file.Path)
+		display.Info("Saving to: %s\n", localPath)
+	}
+
+	startTime := time.Now()
+	d.loadTimestamps([]forge.Content{file})
+	d.downloadEntry(file, localPath)
+
+	if d.ArchiveOutput {
+		if err := d.archive.Close(); err != nil {
+			return err
+		}
+	}
+
+	return d.printSummary(target, startTime)
+}
+
+// printPreviewSummary prints the totals of a preview
+func (d *Downloader) printPreviewSummary(target forge.DownloadTarget) {
+	display.BoldCyan("\nPreview Summary\n")
+	if target.Constraint != "" {
+		display.Info("Tag: %s (matches %s)\n", target.Branch, target.Constraint)
+	}
+	display.Info("Files: %d\n", d.Stats.Files)
+	display.Info("Directories: %d\n", d.Stats.Dirs)
+	if d.Stats.Links > 0 {
+		display.Info("Symlinks: %d\n", d.Stats.Links)
+	}
+	if d.Filter.Active() {
+		display.Info("Filtered out: %d\n", d.Stats.Skipped)
+	}
+}
+
+// printSummary prints the totals of a download and reports failed files
+func (d *Downloader) printSummary(target forge.DownloadTarget, startTime time.Time) error {
 	elapsed := time.Since(startTime).Seconds()
 	display.BoldCyan("\nDownload Summary\n")
+	if target.Constraint != "" {
+		display.Info("Tag: %s (matches %s)\n", target.Branch, target.Constraint)
+	}
 	display.Info("Time: %.1f seconds\n", elapsed)
 	display.Info("Files: %d\n", d.Stats.Files)
 	display.Info("Directories: %d\n", d.Stats.Dirs)
+	if d.Stats.Links > 0 {
+		display.Info("Symlinks: %d\n", d.Stats.Links)
+	}
+	if d.Filter.Active() {
+		display.Info("Filtered out: %d\n", d.Stats.Skipped)
+	}
+	if d.Update && !d.ArchiveOutput {
+		display.Info("New: %d\n", d.Stats.New)
+		display.Info("Changed: %d\n", d.Stats.Changed)
+		display.Info("Unchanged: %d\n", d.Stats.Unchanged)
+	}
+	if d.Sync && !d.ArchiveOutput {
+		display.Info("Removed: %d\n", d.Stats.Removed)
+	}
 	display.Info("Size: %.2f MB\n", float64(d.Stats.Bytes)/(1024*1024))
 
 	if d.Stats.Failures > 0 {
 		display.Warning("Failures: %d\n", d.Stats.Failures)
+		if d.Stats.Integrity > 0 {
+			display.Warning("Integrity errors: %d\n", d.Stats.Integrity)
+		}
 		return fmt.Errorf("%d files failed to download", d.Stats.Failures)
 	} else {
 		display.Success("All files downloaded successfully!\n")
@@ -123,204 +454,738 @@ func (d *Downloader) DownloadRepository(owner, repo, branch, dirPath, localDir s
 	return nil
 }
 
-func (d *Downloader) previewDirectory(owner, repo, branch, dirPath, prefix string) error {
+// resolveTarget determines the ref and directory path of target and pins the
+// ref to a single commit, so that every file is downloaded from the same
+// snapshot of the repository
+func (d *Downloader) resolveTarget(target forge.DownloadTarget) (forge.DownloadTarget, error) {
+	var err error
+
+	if target.Constraint != "" {
+		tags, err := d.source.ListTags(target.Owner, target.Repo)
+		if err != nil {
+			return target, err
+		}
+
+		target.Branch, err = semver.Select(tags, target.Constraint)
+		if err != nil {
+			return target, err
+		}
+	} else if target.RefPath != "" {
+		target.Branch, target.DirPath, err = source.SplitRefPath(d.source, target.Owner, target.Repo, target.RefPath)
+		if err != nil {
+			return target, fmt.Errorf("failed to resolve ref: %w", err)
+		}
+	} else if target.Branch == "" {
+		target.Branch, err = d.defaultBranch(target)
+		if err != nil {
+			return target, err
+		}
+	}
+
+	target.Commit, err = d.source.ResolveRef(target.Owner, target.Repo, target.Branch)
+	if err != nil {
+		return target, err
+	}
+
+	return target, nil
+}
+
+// refLabel describes the resolved ref of target for display, e.g. "main @ 1a2b3c4"
+func refLabel(target forge.DownloadTarget) string {
+	short := target.Commit
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	if strings.HasPrefix(target.Commit, target.Branch) {
+		return short
+	}
+	return target.Branch + " @ " + short
+}
+
+// defaultBranch looks up the default branch of the target's repository,
+// asking the provider only once per repository
+func (d *Downloader) defaultBranch(target forge.DownloadTarget) (string, error) {
+	key := target.Host.APIURL + "/" + target.Owner + "/" + target.Repo
+	if branch, ok := d.defaultBranches[key]; ok {
+		return branch, nil
+	}
+
+	branch, err := d.source.DefaultBranch(target.Owner, target.Repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect default branch: %w", err)
+	}
+	if branch == "" {
+		return "", fmt.Errorf("failed to detect default branch of %s/%s", target.Owner, target.Repo)
+	}
+
+	d.defaultBranches[key] = branch
+	return branch, nil
+}
+
+// listEntries lists every entry below dirPath using the configured traversal mode
+func (d *Downloader) listEntries(provider source.Provider, owner, repo, ref, dirPath string) ([]forge.Content, error) {
+	if d.Traversal == TraversalTree || d.Traversal == TraversalAuto {
+		if lister, ok := provider.(source.TreeLister); ok {
+			contents, err := lister.ListTree(owner, repo, ref, dirPath, d.Recursive)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get directory tree: %w", err)
+			}
+			return contents, nil
+		}
+		if d.Traversal == TraversalTree {
+			display.Warning("Warning: %s does not support tree traversal, listing one directory at a time\n", provider.Name())
+		}
+	}
+
+	return d.listDirectory(provider, owner, repo, ref, dirPath)
+}
+
+// listDirectory lists dirPath one request per directory
+func (d *Downloader) listDirectory(provider source.Provider, owner, repo, ref, dirPath string) ([]forge.Content, error) {
+	contents, err := provider.ListDirectory(owner, repo, ref, dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get directory contents: %w", err)
+	}
+
+	var entries []forge.Content
+	for _, content := range contents {
+		entries = append(entries, content)
+
+		if content.Type == "dir" && d.Recursive {
+			children, err := d.listDirectory(provider, owner, repo, ref, content.Path)
+			if err != nil {
+				display.Warning("Warning: Error in subdirectory %s: %v\n", content.Path, err)
+				continue
+			}
+			entries = append(entries, children...)
+		}
+	}
+
+	return entries, nil
+}
+
+// filterEntries drops the files rejected by the include and exclude patterns,
+// along with the directories left without any file, and counts the skipped
+// files
+func (d *Downloader) filterEntries(entries []forge.Content) []forge.Content {
+	if !d.Filter.Active() {
+		return entries
+	}
+
+	// Directories are kept only if some remaining entry lies below them
+	keptDirs := make(map[string]bool)
+	skipped := 0
+	for _, content := range entries {
+		if content.Type == "dir" {
+			continue
+		}
+		if !d.Filter.Allows(content.Path) {
+			if content.Type == "file" {
+				skipped++
+			}
+			continue
+		}
+		for dir := path.Dir(content.Path); dir != "." && !keptDirs[dir]; dir = path.Dir(dir) {
+			keptDirs[dir] = true
+		}
+	}
+
+	var filtered []forge.Content
+	for _, content := range entries {
+		if content.Type == "dir" && keptDirs[content.Path] || content.Type != "dir" && d.Filter.Allows(content.Path) {
+			filtered = append(filtered, content)
+		}
+	}
+
 	d.Stats.Lock()
-	d.Stats.Dirs++
+	d.Stats.Skipped += skipped
 	d.Stats.Unlock()
 
-	display.Info("%s└── %s/\n", prefix, filepath.Base(dirPath))
-	newPrefix := prefix + "    "
+	return filtered
+}
 
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s", owner, repo, dirPath, branch)
-	contents, err := github.GetContents(apiURL, d.Token)
-	if err != nil {
-		return fmt.Errorf("failed to get directory contents: %w", err)
+func (d *Downloader) previewDirectory(entries []forge.Content, dirPath string) {
+	children := make(map[string][]forge.Content)
+	for _, content := range entries {
+		parent := path.Dir(content.Path)
+		if parent == "." {
+			parent = ""
+		}
+		children[parent] = append(children[parent], content)
+	}
+
+	d.previewLevel(children, strings.Trim(dirPath, "/"), "")
+}
+
+func (d *Downloader) previewLevel(children map[string][]forge.Content, dirPath, prefix string) {
+	d.Stats.Dirs++
+
+	name := path.Base(dirPath)
+	if dirPath == "" {
+		name = "."
 	}
+	display.Info("%s└── %s/\n", prefix, name)
+	newPrefix := prefix + "    "
 
+	contents := children[dirPath]
 	for i, content := range contents {
+		isLast := i == len(contents)-1
 		if content.Type == "file" {
-			d.Stats.Lock()
 			d.Stats.Files++
-			d.Stats.Unlock()
 
-			isLast := i == len(contents)-1
 			if isLast {
 				display.Info("%s└── %s\n", newPrefix, content.Name)
 			} else {
 				display.Info("%s├── %s\n", newPrefix, content.Name)
 			}
+		} else if content.Type == "symlink" {
+			d.Stats.Links++
+
+			if isLast {
+				display.Info("%s└── %s -> %s\n", newPrefix, content.Name, content.Target)
+			} else {
+				display.Info("%s├── %s -> %s\n", newPrefix, content.Name, content.Target)
+			}
 		} else if content.Type == "dir" && d.Recursive {
-			if i == len(contents)-1 {
-				err := d.previewDirectory(owner, repo, branch, filepath.Join(dirPath, content.Name), newPrefix)
-				if err != nil {
-					return err
-				}
+			if isLast {
+				d.previewLevel(children, content.Path, newPrefix)
 			} else {
-				err := d.previewDirectory(owner, repo, branch, filepath.Join(dirPath, content.Name), newPrefix+"│   ")
-				if err != nil {
-					return err
-				}
+				d.previewLevel(children, content.Path, newPrefix+"│   ")
 			}
 		}
 	}
-
-	return nil
 }
 
-func (d *Downloader) downloadDirectory(owner, repo, branch, dirPath, localDir string) error {
+// downloadEntries downloads every listed entry below dirPath into localDir
+func (d *Downloader) downloadEntries(entries []forge.Content, dirPath, localDir string) {
 	d.Stats.Lock()
 	d.Stats.Dirs++
 	d.Stats.Unlock()
 
-	if d.ZipOutput && dirPath != "" {
-		// Add directory entry to zip
-		err := d.zipWriter.CreateDirEntry(dirPath)
+	if d.ArchiveOutput && dirPath != "" {
+		// Add directory entry to the archive
+		err := d.archive.CreateDirEntry(dirPath, d.modTimes[dirPath])
 		if err != nil && d.Verbose {
-			display.Warning("Warning: Could not create zip directory entry: %v\n", err)
+			display.Warning("Warning: Could not create archive directory entry: %v\n", err)
 		}
 	}
 
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s", owner, repo, dirPath, branch)
-	contents, err := github.GetContents(apiURL, d.Token)
-	if err != nil {
-		return fmt.Errorf("failed to get directory contents: %w", err)
-	}
+	for _, content := range entries {
+		localPath := filepath.Join(localDir, filepath.FromSlash(relativePath(content.Path, dirPath)))
 
-	for _, content := range contents {
 		if content.Type == "file" {
 			d.sem <- struct{}{}
 			d.wg.Add(1)
 
-			go func(content github.Content) {
+			go func(content forge.Content, filePath string) {
 				defer d.wg.Done()
 				defer func() { <-d.sem }()
 
-				filePath := filepath.Join(localDir, content.Name)
-
-				// Check if updating and file already exists
-				if d.Update && !d.ZipOutput {
-					if stat, err := os.Stat(filePath); err == nil {
-						// File exists, check if we need to update it
-						if !d.shouldUpdate(content, stat) {
-							if d.Verbose {
-								display.Info("Skipped (up-to-date): %s\n", content.Path)
-							}
-							return
-						}
-					}
-				}
+				d.downloadEntry(content, filePath)
+			}(content, localPath)
+		} else if content.Type == "symlink" {
+			d.createSymlink(content, localPath)
+		} else if content.Type == "dir" && d.Recursive {
+			d.Stats.Lock()
+			d.Stats.Dirs++
+			d.Stats.Unlock()
 
-				var size int64
-				var err error
-				attempts := 0
-				maxAttempts := d.Retries + 1
-
-				for attempts < maxAttempts {
-					attempts++
-					if attempts > 1 && d.Verbose {
-						display.Warning("Retry %d/%d: %s\n", attempts-1, d.Retries, content.Path)
-					}
-
-					if d.ZipOutput {
-						size, err = d.downloadFileToZip(content.DownloadURL, content.Path)
-					} else {
-						size, err = d.downloadFile(content.DownloadURL, filePath)
-					}
-
-					if err == nil {
-						break
-					}
-
-					if attempts < maxAttempts {
-						// Exponential backoff: wait 2^attempt * 100ms
-						backoff := (1 << (attempts - 1)) * 100
-						time.Sleep(time.Duration(backoff) * time.Millisecond)
-					}
+			if d.ArchiveOutput {
+				err := d.archive.CreateDirEntry(content.Path, d.modTime(content))
+				if err != nil && d.Verbose {
+					display.Warning("Warning: Could not create archive directory entry: %v\n", err)
 				}
+				continue
+			}
 
+			if err := os.MkdirAll(localPath, 0755); err != nil {
+				display.Error("Error creating subdirectory %s: %v\n", localPath, err)
 				d.Stats.Lock()
-				defer d.Stats.Unlock()
-
-				if err != nil {
-					display.Error("Failed: %s (%v)\n", content.Path, err)
-					d.Stats.Failures++
-				} else {
-					if d.Verbose {
-						display.Success("Downloaded: %s (%.2f KB)\n", content.Path, float64(size)/1024)
-					}
-					d.Stats.Files++
-					d.Stats.Bytes += size
-				}
-			}(content)
-		} else if content.Type == "dir" && d.Recursive {
-			subDir := filepath.Join(localDir, content.Name)
-			if !d.ZipOutput {
-				if err := os.MkdirAll(subDir, 0755); err != nil {
-					display.Error("Error creating subdirectory %s: %v\n", subDir, err)
-					d.Stats.Lock()
-					d.Stats.Failures++
-					d.Stats.Unlock()
-					continue
-				}
+				d.Stats.Failures++
+				d.Stats.Unlock()
 			}
+		}
+	}
+}
 
-			subPath := filepath.Join(dirPath, content.Name)
-			if err := d.downloadDirectory(owner, repo, branch, subPath, subDir); err != nil {
-				display.Warning("Warning: Error in subdirectory %s: %v\n", content.Path, err)
+// downloadEntry downloads a single file, retrying with exponential backoff
+func (d *Downloader) downloadEntry(content forge.Content, filePath string) {
+	exists, skip := d.checkExisting(content, filePath)
+	if skip {
+		return
+	}
+
+	var size int64
+	var err error
+	attempts := 0
+	maxAttempts := d.Retries + 1
+
+	for attempts < maxAttempts {
+		attempts++
+		if attempts > 1 && d.Verbose {
+			display.Warning("Retry %d/%d: %s\n", attempts-1, d.Retries, content.Path)
+		}
+
+		if d.ArchiveOutput {
+			size, err = d.downloadFileToArchive(content, content.Path)
+		} else {
+			if mkErr := os.MkdirAll(filepath.Dir(filePath), 0755); mkErr != nil {
+				err = fmt.Errorf("failed to create directory: %w", mkErr)
+				break
 			}
+			size, err = d.downloadFile(content, filePath)
+			if err == nil {
+				err = d.applyModTime(content, filePath)
+			}
+		}
+
+		if err == nil {
+			break
+		}
+
+		if errors.Is(err, ErrIntegrity) && attempts < maxAttempts {
+			display.Warning("Integrity check failed: %s, retrying\n", content.Path)
+		}
+
+		if attempts < maxAttempts {
+			// Exponential backoff: wait 2^attempt * 100ms
+			backoff := (1 << (attempts - 1)) * 100
+			time.Sleep(time.Duration(backoff) * time.Millisecond)
 		}
 	}
 
-	return nil
+	if err != nil {
+		d.Stats.Lock()
+		defer d.Stats.Unlock()
+
+		display.Error("Failed: %s (%v)\n", content.Path, err)
+		d.Stats.Failures++
+		if errors.Is(err, ErrIntegrity) {
+			d.Stats.Integrity++
+		}
+		return
+	}
+
+	d.countDownload(content, filePath, size, exists)
 }
 
-// shouldUpdate determines if a file needs to be updated based on the update mode
-func (d *Downloader) shouldUpdate(content github.Content, stat os.FileInfo) bool {
-	// For now, just update based on file size
-	// In a real implementation, you might use ETag, modified timestamp, or file hash
+// checkExisting looks at the local copy of a file during an update. It
+// reports whether there is one and whether it is up to date, in which case
+// its mode and modification time are fixed and it is counted as unchanged.
+func (d *Downloader) checkExisting(content forge.Content, filePath string) (exists, skip bool) {
+	if !d.Update || d.ArchiveOutput {
+		return false, false
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return false, false
+	}
+	if d.shouldUpdate(content, filePath, stat) {
+		return true, false
+	}
+
+	if err := applyMode(content, filePath); err != nil {
+		display.Warning("Warning: %v\n", err)
+	}
+	if err := d.applyModTime(content, filePath); err != nil {
+		display.Warning("Warning: %v\n", err)
+	}
+	// The entry is stored once the modification time is final, since a
+	// later change would invalidate it
+	if githash.IsObjectID(content.SHA) {
+		d.hashes.store(filePath, content.SHA)
+	}
+	if d.Verbose {
+		display.Info("Skipped (up-to-date): %s\n", content.Path)
+	}
+	d.Stats.Lock()
+	d.Stats.Unchanged++
+	d.Stats.Unlock()
+
+	return true, true
+}
+
+// countDownload records a downloaded file in the statistics and the hash
+// cache
+func (d *Downloader) countDownload(content forge.Content, filePath string, size int64, exists bool) {
+	d.Stats.Lock()
+	defer d.Stats.Unlock()
+
+	if d.Verbose {
+		display.Success("Downloaded: %s (%.2f KB)\n", content.Path, float64(size)/1024)
+	}
+	d.Stats.Files++
+	d.Stats.Bytes += size
+
+	if d.hashes != nil {
+		if exists {
+			d.Stats.Changed++
+		} else {
+			d.Stats.New++
+		}
+		if githash.IsObjectID(content.SHA) {
+			d.hashes.store(filePath, content.SHA)
+		}
+	}
+}
+
+// relativePath returns the repository path p relative to dirPath
+func relativePath(p, dirPath string) string {
+	dirPath = strings.Trim(dirPath, "/")
+	if dirPath == "" {
+		return p
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, dirPath), "/")
+}
+
+// shouldUpdate determines if an existing file needs to be updated. When the
+// listing reports the blob ID of the file, the local copy is hashed the way
+// git does and compared with it. Otherwise a file is stale when its size
+// differs, or when it is older than the commit that last changed it.
+func (d *Downloader) shouldUpdate(content forge.Content, filePath string, stat os.FileInfo) bool {
+	if githash.IsObjectID(content.SHA) {
+		sha, err := d.localSHA(content, filePath, stat)
+		if err == nil {
+			return sha != content.SHA && !d.matchesLFSObject(content, filePath)
+		}
+		display.Warning("Warning: %v\n", err)
+	}
+
+	if t := d.modTime(content); !t.IsZero() && stat.ModTime().Before(t) {
+		return true
+	}
 	return stat.Size() == 0 || content.Size != stat.Size()
 }
 
-func (d *Downloader) downloadFileToZip(url, zipPath string) (int64, error) {
-	data, err := github.DownloadFileContent(url, d.Token)
+// localSHA returns the blob ID of the local copy of content, from the hash
+// cache when the file has not changed since it was last hashed. For a
+// resolved Git LFS object the cache holds the blob ID of its pointer.
+func (d *Downloader) localSHA(content forge.Content, filePath string, stat os.FileInfo) (string, error) {
+	if sha, ok := d.hashes.lookup(filePath, stat); ok {
+		return sha, nil
+	}
+
+	return githash.HashFile(filePath, content.SHA)
+}
+
+// matchesLFSObject reports whether the local copy of content is the Git LFS
+// object it points to. A resolved object never hashes to the blob ID of its
+// pointer, so the pointer is fetched and the file is checked against the
+// size and sha256 it records instead.
+func (d *Downloader) matchesLFSObject(content forge.Content, filePath string) bool {
+	if d.KeepLFSPointers || content.Size > lfs.MaxPointerSize {
+		return false
+	}
+
+	provider, _ := d.sourceFor(content.Path)
+	data, err := source.ReadFile(provider, content)
+	if err != nil {
+		return false
+	}
+	pointer, ok := lfs.ParsePointer(data)
+	if !ok {
+		return false
+	}
+
+	ok, err = pointer.MatchesFile(filePath)
+	if err != nil {
+		display.Warning("Warning: %v\n", err)
+		return false
+	}
+	return ok
+}
+
+// openFile starts downloading a file, resuming an earlier download when
+// from is set. If it is a Git LFS pointer and pointers are not kept, the
+// pointer is returned instead of a response so that the object can be
+// streamed to its destination. Only the first bytes of the file are read to
+// tell.
+func (d *Downloader) openFile(content forge.Content, from resume.Point) (*resume.Response, *lfs.Pointer, error) {
+	provider, _ := d.sourceFor(content.Path)
+	resp, err := provider.OpenFile(content, from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A resumed download continues content that was not a pointer
+	if d.KeepLFSPointers || resp.Offset > 0 {
+		return resp, nil, nil
+	}
+
+	body, head, pointer, err := peekPointer(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	if pointer == nil {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, resp.Body}
+		return resp, nil, nil
+	}
+	resp.Body.Close()
+
+	// The blob ID is that of the pointer, not of the object
+	if err := verifyBlob(content, head); err != nil {
+		return nil, nil, err
+	}
+	if d.Verbose {
+		display.Info("Resolving LFS object: %s (%.2f KB)\n", content.Path, float64(pointer.Size)/1024)
+	}
+	return nil, pointer, nil
+}
+
+// peekPointer reads as much of r as a Git LFS pointer can hold and returns
+// the pointer along with its data if r is one. Otherwise it returns a reader
+// for the whole content of r.
+func peekPointer(r io.Reader) (io.Reader, []byte, *lfs.Pointer, error) {
+	// Anything that does not end within the size limit of pointers is content
+	buffered := bufio.NewReaderSize(r, lfs.MaxPointerSize+1)
+	head, err := buffered.Peek(lfs.MaxPointerSize + 1)
+	if err != nil && err != io.EOF {
+		return nil, nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	pointer, ok := lfs.ParsePointer(head)
+	if !ok {
+		return buffered, nil, nil, nil
+	}
+	return nil, head, &pointer, nil
+}
+
+// verifyBlob checks data against the blob ID content was listed with. Files
+// listed without a blob ID are not checked.
+func verifyBlob(content forge.Content, data []byte) error {
+	if !githash.IsObjectID(content.SHA) {
+		return nil
+	}
+
+	h := githash.NewBlob(int64(len(data)), content.SHA)
+	h.Write(data)
+	return checkBlob(content, h)
+}
+
+// checkBlob compares the sum of h, a blob hash, with the blob ID content was
+// listed with
+func checkBlob(content forge.Content, h hash.Hash) error {
+	if sum := githash.Sum(h); sum != content.SHA {
+		return fmt.Errorf("%w: received blob %s, expected %s", ErrIntegrity, sum, content.SHA)
+	}
+	return nil
+}
+
+// copyVerified streams a file from body to f, after the first offset bytes
+// f already holds, verifying the whole file against its blob ID along the
+// way. The blob ID covers the size, so when the listing gave none or a wrong
+// one, f is read back to hash it with the size received. It returns the
+// size of the file.
+func copyVerified(f *os.File, offset int64, body io.Reader, content forge.Content) (int64, error) {
+	if !githash.IsObjectID(content.SHA) {
+		n, err := io.Copy(f, body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to download file: %w", err)
+		}
+		return offset + n, nil
+	}
+
+	h := githash.NewBlob(content.Size, content.SHA)
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, offset)); err != nil {
+		return 0, fmt.Errorf("failed to hash partial file: %w", err)
+	}
+
+	n, err := io.Copy(io.MultiWriter(f, h), body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download file: %w", err)
+	}
+	n += offset
+
+	if n != content.Size {
+		h = githash.NewBlob(n, content.SHA)
+		if _, err := io.Copy(h, io.NewSectionReader(f, 0, n)); err != nil {
+			return 0, fmt.Errorf("failed to hash file: %w", err)
+		}
+	}
+
+	if err := checkBlob(content, h); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// openLFSObject starts downloading the object behind the pointer found at
+// content. A resumed download reads the bytes received so far from prefix
+// to verify them.
+func (d *Downloader) openLFSObject(content forge.Content, pointer lfs.Pointer, from resume.Point, prefix io.Reader) (*resume.Response, error) {
+	_, endpoint := d.sourceFor(content.Path)
+	return lfs.Open(endpoint, pointer, from, prefix)
+}
+
+// copyLFSObject streams an object opened with openLFSObject to w, which
+// verifies its size and sha256 oid
+func copyLFSObject(resp *resume.Response, w io.Writer) (n int64, err error) {
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close LFS object: %w", cerr)
+		}
+	}()
+
+	n, err = io.Copy(w, resp.Body)
+	if errors.Is(err, lfs.ErrMismatch) {
+		return 0, fmt.Errorf("%w: %w", ErrIntegrity, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to download LFS object: %w", err)
+	}
+
+	return resp.Offset + n, nil
+}
+
+func (d *Downloader) downloadFileToArchive(content forge.Content, archivePath string) (int64, error) {
+	resp, pointer, err := d.openFile(content, resume.Point{})
+	if err != nil {
+		return 0, err
+	}
+	if pointer != nil {
+		resp, err = d.openLFSObject(content, *pointer, resume.Point{}, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer resp.Body.Close()
+
+	return d.addToArchive(content, archivePath, func(spool *os.File) (int64, error) {
+		if pointer != nil {
+			return copyLFSObject(resp, spool)
+		}
+		return copyVerified(spool, 0, resp.Body, content)
+	})
+}
+
+// addToArchive spools a file to a temporary file before adding it to the
+// archive, so that a failed or corrupt transfer never leaves a partial entry
+// in it. fill writes the verified file to the spool and returns its size.
+func (d *Downloader) addToArchive(content forge.Content, archivePath string, fill func(spool *os.File) (int64, error)) (int64, error) {
+	spool, err := os.CreateTemp("", config.AppName+"-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	n, err := fill(spool)
 	if err != nil {
 		return 0, err
 	}
 
-	err = d.zipWriter.AddFile(data, zipPath)
+	stat, err := spool.Stat()
 	if err != nil {
+		return 0, fmt.Errorf("failed to stat temporary file: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind temporary file: %w", err)
+	}
+
+	perm, _ := fileMode(content.Mode)
+	if err := d.archive.AddFile(spool, archivePath, stat.Size(), perm, d.modTime(content)); err != nil {
 		return 0, err
 	}
 
-	return int64(len(data)), nil
+	return n, nil
 }
 
-func (d *Downloader) downloadFile(url, filePath string) (int64, error) {
-	data, err := github.DownloadFileContent(url, d.Token)
+// downloadFile streams a file to its partial file and moves it to filePath
+// once it is complete and verified, so that filePath never holds a partial
+// or corrupt file. A transfer that breaks off is resumed by the next
+// attempt, while content that fails verification is discarded.
+func (d *Downloader) downloadFile(content forge.Content, filePath string) (int64, error) {
+	partPath := partialPath(filePath)
+	part, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
+		return 0, fmt.Errorf("failed to create partial file: %w", err)
+	}
+
+	n, err := d.fillPartial(content, part)
+	if err == nil {
+		err = applyMode(content, partPath)
+	}
+	if err != nil {
+		part.Close()
+		// Keep what the next attempt can resume, unless it is corrupt
+		if errors.Is(err, ErrIntegrity) || !resumable(partPath) {
+			removePartial(partPath)
+		}
+		return 0, err
+	}
+
+	if err := commitTemp(part, filePath); err != nil {
+		removePartial(partPath)
 		return 0, err
 	}
+	removePartial(partPath)
+
+	return n, nil
+}
+
+// fillPartial downloads content into its partial file part, continuing
+// where an earlier attempt stopped if the server allows it
+func (d *Downloader) fillPartial(content forge.Content, part *os.File) (int64, error) {
+	id := content.SHA
+	if id == "" {
+		id = content.DownloadURL
+	}
 
-	out, err := os.Create(filePath)
+	resp, pointer, err := d.openFile(content, resumePoint(part.Name(), id))
 	if err != nil {
-		return 0, fmt.Errorf("failed to create file: %w", err)
+		return 0, err
 	}
 
-	// Use a named return to capture close error properly
-	var writeErr error
-	defer func() {
-		if cerr := out.Close(); cerr != nil {
-			if writeErr == nil {
-				writeErr = fmt.Errorf("failed to close file: %w", cerr)
-			}
+	if pointer == nil {
+		defer resp.Body.Close()
+		if err := startPartial(part, id, resp); err != nil {
+			return 0, fmt.Errorf("failed to prepare partial file: %w", err)
 		}
-	}()
+		return copyVerified(part, resp.Offset, resp.Body, content)
+	}
 
-	n, err := out.Write(data)
+	id = pointer.OID
+	from := resumePoint(part.Name(), id)
+	resp, err = d.openLFSObject(content, *pointer, from, io.NewSectionReader(part, 0, from.Offset))
 	if err != nil {
-		writeErr = fmt.Errorf("failed to write file data: %w", err)
-		return 0, writeErr
+		return 0, err
+	}
+	if err := startPartial(part, id, resp); err != nil {
+		resp.Body.Close()
+		return 0, fmt.Errorf("failed to prepare partial file: %w", err)
+	}
+	return copyLFSObject(resp, part)
+}
+
+// fileMode converts a git file mode such as 100755 to permission bits. It
+// reports false when the mode is unknown or not that of a regular file.
+func fileMode(gitMode string) (os.FileMode, bool) {
+	mode, err := strconv.ParseUint(gitMode, 8, 32)
+	if err != nil || mode&0170000 != 0100000 {
+		return 0, false
+	}
+
+	// Git only records whether a file is executable
+	if mode&0111 != 0 {
+		return 0755, true
+	}
+	return 0644, true
+}
+
+// applyMode sets the permissions of a downloaded file from its git file mode
+func applyMode(content forge.Content, filePath string) error {
+	perm, ok := fileMode(content.Mode)
+	if !ok {
+		return nil
 	}
 
-	return int64(n), writeErr
+	if err := os.Chmod(filePath, perm); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/github"
+)
+
+func TestListEntriesModes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/commits/abc":
+			w.Write([]byte(`{"sha": "abc", "commit": {"tree": {"sha": "root"}}}`))
+		case "/repos/o/r/git/trees/root":
+			w.Write([]byte(`{"sha": "root", "tree": [
+				{"path": "run.sh", "mode": "100755", "type": "blob", "sha": "1", "size": 3},
+				{"path": "README", "mode": "100644", "type": "blob", "sha": "2", "size": 5}
+			]}`))
+		case "/repos/o/r/contents/":
+			w.Write([]byte(`[
+				{"name": "run.sh", "path": "run.sh", "type": "file", "sha": "1", "size": 3, "download_url": "http://example.com/run.sh"},
+				{"name": "README", "path": "README", "type": "file", "sha": "2", "size": 5, "download_url": "http://example.com/README"}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := github.NewClient(forge.Host{APIURL: server.URL, RawURL: server.URL + "/raw"}, "")
+
+	tests := []struct {
+		traversal string
+		want      string
+	}{
+		{traversal: TraversalAuto, want: "100755"},
+		{traversal: TraversalTree, want: "100755"},
+		{traversal: TraversalContents, want: ""},
+	}
+
+	for _, tt := range tests {
+		d := New("", tt.traversal, true, 1, false, false, false, false, 0)
+		entries, err := d.listEntries(provider, "o", "r", "abc", "")
+		if err != nil {
+			t.Errorf("listEntries with %s traversal failed: %v", tt.traversal, err)
+			continue
+		}
+		if len(entries) != 2 || entries[0].Path != "run.sh" || entries[0].Mode != tt.want {
+			t.Errorf("listEntries with %s traversal = %+v, want run.sh with mode %q", tt.traversal, entries, tt.want)
+		}
+	}
+}
+
+func TestCheckExisting(t *testing.T) {
+	object := "large binary content"
+	sum := sha256.Sum256([]byte(object))
+	pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", hex.EncodeToString(sum[:]), len(object))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pointer))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		filePath := filepath.Join(dir, name)
+		if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filePath
+	}
+
+	stamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := New("", TraversalAuto, true, 1, false, false, false, true, 0)
+	d.source = github.NewClient(forge.Host{APIURL: server.URL, RawURL: server.URL}, "")
+	d.hashes = &hashCache{entries: make(map[string]hashEntry)}
+	d.modTimes = map[string]time.Time{"hello": stamp, "model.bin": stamp}
+
+	tests := []struct {
+		content  forge.Content
+		data     string
+		wantSkip bool
+	}{
+		{content: forge.Content{Path: "hello", SHA: "ce013625030ba8dba906f756967f9e9ca394464a", Size: 6}, data: "hello\n", wantSkip: true},
+		{content: forge.Content{Path: "stale", SHA: "ce013625030ba8dba906f756967f9e9ca394464a", Size: 6}, data: "hallo\n", wantSkip: false},
+		{content: forge.Content{Path: "model.bin", SHA: strings.Repeat("1", 40), Size: int64(len(pointer))}, data: object, wantSkip: true},
+		{content: forge.Content{Path: "changed.bin", SHA: strings.Repeat("1", 40), Size: int64(len(pointer))}, data: "other binary content", wantSkip: false},
+	}
+
+	for _, tt := range tests {
+		tt.content.DownloadURL = server.URL + "/" + tt.content.Path
+		filePath := write(tt.content.Path, tt.data)
+
+		exists, skip := d.checkExisting(tt.content, filePath)
+		if !exists || skip != tt.wantSkip {
+			t.Errorf("checkExisting(%s) = %v, %v, want true, %v", tt.content.Path, exists, skip, tt.wantSkip)
+			continue
+		}
+		if !skip {
+			continue
+		}
+
+		// The cached entry must hold for the file as it was left
+		stat, err := os.Stat(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stat.ModTime().Equal(stamp) {
+			t.Errorf("%s modified at %v, want %v", tt.content.Path, stat.ModTime(), stamp)
+		}
+		if sha, ok := d.hashes.lookup(filePath, stat); !ok || sha != tt.content.SHA {
+			t.Errorf("cached blob ID of %s = %q, %v, want %s", tt.content.Path, sha, ok, tt.content.SHA)
+		}
+	}
+}
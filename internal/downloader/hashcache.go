@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/liagha/gitdig/internal/config"
+)
+
+// hashCache remembers the git blob IDs of local files between runs, so that
+// update checks do not hash every file of a large tree again. An entry is
+// only trusted while the file keeps the size and modification time it had
+// when the entry was stored.
+type hashCache struct {
+	path    string
+	entries map[string]hashEntry
+	mu      sync.Mutex
+}
+
+type hashEntry struct {
+	Size int64 `json:"size"`
+	// ModTime is in nanoseconds since the Unix epoch
+	ModTime int64  `json:"mtime"`
+	SHA     string `json:"sha"`
+}
+
+// hashCachePath returns the location of the hash cache
+func hashCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, config.AppName, "hashes.json"), nil
+}
+
+// loadHashCache reads the hash cache. A missing or unreadable cache starts
+// out empty.
+func loadHashCache() *hashCache {
+	cache := &hashCache{entries: make(map[string]hashEntry)}
+
+	path, err := hashCachePath()
+	if err != nil {
+		return cache
+	}
+	cache.path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		cache.entries = make(map[string]hashEntry)
+	}
+
+	return cache
+}
+
+// lookup returns the blob ID stored for the file at filePath, if the file
+// has not changed since
+func (c *hashCache) lookup(filePath string, stat os.FileInfo) (string, bool) {
+	key, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.Size != stat.Size() || entry.ModTime != stat.ModTime().UnixNano() {
+		return "", false
+	}
+	return entry.SHA, true
+}
+
+// store records sha as the blob ID of the file at filePath in its current
+// state
+func (c *hashCache) store(filePath, sha string) {
+	key, err := filepath.Abs(filePath)
+	if err != nil {
+		return
+	}
+
+	stat, err := os.Stat(key)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = hashEntry{Size: stat.Size(), ModTime: stat.ModTime().UnixNano(), SHA: sha}
+}
+
+// save writes the cache back, leaving out files that no longer exist
+func (c *hashCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if _, err := os.Stat(key); errors.Is(err, os.ErrNotExist) {
+			delete(c.entries, key)
+		}
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode hash cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(c.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// lockFileName is the name of the lockfile written into a downloaded
+// directory. Archives get a lockfile named after them instead.
+const lockFileName = ".gitdig.lock"
+
+// lockFileVersion is the version of the lockfile format
+const lockFileVersion = 1
+
+// lockFile records exactly what a download fetched, so that it can be
+// reproduced with -locked
+type lockFile struct {
+	Version  int          `json:"version"`
+	Source   string       `json:"source"`
+	Provider string       `json:"provider"`
+	APIURL   string       `json:"api_url,omitempty"`
+	Owner    string       `json:"owner"`
+	Repo     string       `json:"repo"`
+	Ref      string       `json:"ref"`
+	Commit   string       `json:"commit"`
+	Path     string       `json:"path"`
+	Include  []string     `json:"include,omitempty"`
+	Exclude  []string     `json:"exclude,omitempty"`
+	Files    []lockedFile `json:"files"`
+}
+
+// lockedFile is a file or symlink recorded in a lockfile. Paths are relative
+// to the repository root, sizes and blob IDs are those of the git blob.
+type lockedFile struct {
+	Path string `json:"path"`
+	SHA  string `json:"sha"`
+	Size int64  `json:"size"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// lockPath returns the location of the lockfile of target
+func (d *Downloader) lockPath(target forge.DownloadTarget) string {
+	if d.ArchiveOutput {
+		return trimArchiveExtension(ArchivePath(target.LocalDir, d.Format)) + lockFileName
+	}
+	return filepath.Join(target.LocalDir, lockFileName)
+}
+
+// newLockFile records target, resolved to a commit, and the files among
+// entries
+func (d *Downloader) newLockFile(target forge.DownloadTarget, entries []forge.Content) lockFile {
+	return lockFile{
+		Version:  lockFileVersion,
+		Source:   target.Source,
+		Provider: target.Provider,
+		APIURL:   target.Host.APIURL,
+		Owner:    target.Owner,
+		Repo:     target.Repo,
+		Ref:      target.Branch,
+		Commit:   target.Commit,
+		Path:     strings.Trim(target.DirPath, "/"),
+		Include:  d.Filter.Include,
+		Exclude:  d.Filter.Exclude,
+		Files:    lockedFiles(entries),
+	}
+}
+
+// lockedFiles lists the files and symlinks among entries, sorted by path
+func lockedFiles(entries []forge.Content) []lockedFile {
+	files := []lockedFile{}
+	for _, content := range entries {
+		if content.Type != "file" && content.Type != "symlink" {
+			continue
+		}
+
+		mode := content.Mode
+		if mode == "" && content.Type == "symlink" {
+			mode = "120000"
+		}
+		files = append(files, lockedFile{Path: content.Path, SHA: content.SHA, Size: content.Size, Mode: mode})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// readLockFile reads the lockfile at lockPath
+func readLockFile(lockPath string) (lockFile, error) {
+	var lock lockFile
+
+	data, err := os.ReadFile(lockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, fmt.Errorf("no lockfile found at %s, download once with -lock to create it", lockPath)
+	}
+	if err != nil {
+		return lock, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("failed to parse lockfile %s: %w", lockPath, err)
+	}
+	if lock.Version != lockFileVersion {
+		return lock, fmt.Errorf("unsupported lockfile version %d in %s", lock.Version, lockPath)
+	}
+
+	return lock, nil
+}
+
+// writeLockFile writes lock to lockPath
+func writeLockFile(lockPath string, lock lockFile) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for lockfile: %w", err)
+	}
+	if err := writeFileAtomic(lockPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+
+	return nil
+}
+
+// pin checks that lock was written for target with the current filters and
+// pins target to the recorded ref, path and commit
+func (d *Downloader) pin(target forge.DownloadTarget, lock lockFile) (forge.DownloadTarget, error) {
+	if lock.Source != target.Source || lock.Provider != target.Provider || lock.APIURL != target.Host.APIURL {
+		return target, fmt.Errorf("the lockfile was written for %s, not %s", lock.Source, target.Source)
+	}
+	if !slices.Equal(lock.Include, d.Filter.Include) || !slices.Equal(lock.Exclude, d.Filter.Exclude) {
+		return target, errors.New("the include/exclude patterns differ from those in the lockfile")
+	}
+
+	target.Branch = lock.Ref
+	target.DirPath = lock.Path
+	target.RefPath = ""
+	target.Constraint = ""
+	target.Commit = lock.Commit
+
+	return target, nil
+}
+
+// verify compares the files among entries, the upstream listing, with those
+// recorded in lock and describes every difference. File modes are only
+// compared when both sides know them.
+func (lock lockFile) verify(entries []forge.Content) error {
+	upstream := make(map[string]lockedFile)
+	for _, file := range lockedFiles(entries) {
+		upstream[file.Path] = file
+	}
+
+	var differences []string
+	for _, locked := range lock.Files {
+		file, ok := upstream[locked.Path]
+		delete(upstream, locked.Path)
+
+		switch {
+		case !ok:
+			differences = append(differences, "missing upstream: "+locked.Path)
+		case file.SHA != locked.SHA || file.Size != locked.Size:
+			differences = append(differences, "changed upstream: "+locked.Path)
+		case file.Mode != "" && locked.Mode != "" && file.Mode != locked.Mode:
+			differences = append(differences, fmt.Sprintf("mode changed upstream: %s (%s, locked %s)", locked.Path, file.Mode, locked.Mode))
+		}
+	}
+	for p := range upstream {
+		differences = append(differences, "not in lockfile: "+p)
+	}
+
+	if len(differences) == 0 {
+		return nil
+	}
+
+	sort.Strings(differences)
+	return fmt.Errorf("the upstream tree no longer matches the lockfile:\n  %s", strings.Join(differences, "\n  "))
+}
@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/glob"
+)
+
+func TestLockFileVerify(t *testing.T) {
+	entries := []forge.Content{
+		{Path: "docs", Type: "dir"},
+		{Path: "docs/a.md", Type: "file", SHA: "a1", Size: 10},
+		{Path: "docs/run.sh", Type: "file", SHA: "b1", Size: 20, Mode: "100755"},
+		{Path: "docs/link", Type: "symlink", SHA: "c1", Size: 4},
+	}
+	lock := lockFile{Files: lockedFiles(entries)}
+
+	if err := lock.verify(entries); err != nil {
+		t.Fatalf("verify of the locked listing failed: %v", err)
+	}
+
+	// Listings without modes, such as the contents API, still match
+	withoutModes := append([]forge.Content(nil), entries...)
+	withoutModes[2].Mode = ""
+	if err := lock.verify(withoutModes); err != nil {
+		t.Errorf("verify without modes failed: %v", err)
+	}
+
+	changed := []forge.Content{
+		{Path: "docs/a.md", Type: "file", SHA: "a2", Size: 10},
+		{Path: "docs/run.sh", Type: "file", SHA: "b1", Size: 20, Mode: "100644"},
+		{Path: "docs/new.md", Type: "file", SHA: "d1", Size: 1},
+	}
+	err := lock.verify(changed)
+	if err == nil {
+		t.Fatal("verify of a changed listing succeeded")
+	}
+	for _, want := range []string{
+		"changed upstream: docs/a.md",
+		"mode changed upstream: docs/run.sh (100644, locked 100755)",
+		"missing upstream: docs/link",
+		"not in lockfile: docs/new.md",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("verify error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLockFileRoundTrip(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "out", lockFileName)
+	lock := lockFile{
+		Version: lockFileVersion,
+		Source:  "owner/repo/tree/main/docs",
+		Commit:  "abc",
+		Files:   lockedFiles([]forge.Content{{Path: "b", Type: "file"}, {Path: "a", Type: "symlink"}}),
+	}
+
+	if err := writeLockFile(lockPath, lock); err != nil {
+		t.Fatalf("writeLockFile failed: %v", err)
+	}
+	read, err := readLockFile(lockPath)
+	if err != nil {
+		t.Fatalf("readLockFile failed: %v", err)
+	}
+	if read.Source != lock.Source || len(read.Files) != 2 || read.Files[0].Path != "a" || read.Files[0].Mode != "120000" {
+		t.Errorf("readLockFile = %+v, want %+v", read, lock)
+	}
+
+	if _, err := readLockFile(filepath.Join(t.TempDir(), lockFileName)); err == nil || !strings.Contains(err.Error(), "download once with -lock") {
+		t.Errorf("readLockFile of a missing lockfile = %v, want a hint to use -lock", err)
+	}
+}
+
+func TestPin(t *testing.T) {
+	target := forge.DownloadTarget{
+		Source:     "owner/repo@^1",
+		Provider:   forge.ProviderGitHub,
+		Host:       forge.Host{APIURL: "https://api.github.com"},
+		Branch:     "main",
+		RefPath:    "main/docs",
+		Constraint: "^1",
+	}
+	lock := lockFile{
+		Source:   target.Source,
+		Provider: target.Provider,
+		APIURL:   target.Host.APIURL,
+		Ref:      "v1.4.0",
+		Commit:   "abc",
+		Path:     "docs",
+		Include:  []string{"*.md"},
+	}
+
+	d := &Downloader{Filter: glob.Filter{Include: []string{"*.md"}}}
+	pinned, err := d.pin(target, lock)
+	if err != nil {
+		t.Fatalf("pin failed: %v", err)
+	}
+	if pinned.Branch != "v1.4.0" || pinned.Commit != "abc" || pinned.DirPath != "docs" || pinned.RefPath != "" || pinned.Constraint != "" {
+		t.Errorf("pin = %+v, want the ref, commit and path of the lockfile", pinned)
+	}
+
+	other := target
+	other.Source = "owner/other"
+	if _, err := d.pin(other, lock); err == nil || !strings.Contains(err.Error(), "was written for owner/repo@^1") {
+		t.Errorf("pin of another target = %v, want an error", err)
+	}
+
+	d.Filter.Exclude = []string{"draft/**"}
+	if _, err := d.pin(target, lock); err == nil || !strings.Contains(err.Error(), "patterns differ") {
+		t.Errorf("pin with other patterns = %v, want an error", err)
+	}
+}
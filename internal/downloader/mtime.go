@@ -0,0 +1,167 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liagha/gitdig/internal/display"
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// Timestamp modes, which decide the modification times of downloaded files
+const (
+	// TimestampsNow leaves files with the time they were downloaded
+	TimestampsNow = "now"
+	// TimestampsCommit stamps every file with the date of the downloaded commit
+	TimestampsCommit = "commit"
+	// TimestampsFile stamps each file with the date of the last commit that
+	// changed it
+	TimestampsFile = "file"
+)
+
+// timestampGroup is a set of files of one repository whose commit times are
+// looked up together
+type timestampGroup struct {
+	repo  submodule
+	paths []string
+}
+
+// loadTimestamps looks up the modification times of entries according to
+// the timestamp mode. Files are looked up one directory at a time, several
+// directories at once. Files whose time cannot be found keep the time they
+// are downloaded at.
+func (d *Downloader) loadTimestamps(entries []forge.Content) {
+	d.modTimes = nil
+	if d.Timestamps != TimestampsCommit && d.Timestamps != TimestampsFile {
+		return
+	}
+
+	groups := make(map[string]*timestampGroup)
+	var keys []string
+	files := 0
+	for _, content := range entries {
+		if content.Type != "file" && content.Type != "symlink" {
+			continue
+		}
+
+		// In commit mode every file of a repository shares one lookup
+		repo := d.repositoryOf(content.Path)
+		key := repo.path
+		if d.Timestamps == TimestampsFile {
+			key += "\x00" + path.Dir(content.Path)
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &timestampGroup{repo: repo}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.paths = append(group.paths, content.Path)
+		files++
+	}
+
+	if d.Verbose {
+		display.Info("Looking up commit times of %d files\n", files)
+	}
+
+	d.modTimes = make(map[string]time.Time)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		group := groups[key]
+
+		d.sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-d.sem }()
+
+			times, err := d.commitTimes(group)
+			if err != nil {
+				display.Warning("Warning: Could not look up commit times: %v\n", err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for p, t := range times {
+				d.modTimes[p] = t
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Directories take the time of the newest file below them, which
+	// archives store with their directory entries
+	dirTimes := make(map[string]time.Time)
+	for p, t := range d.modTimes {
+		for dir := pathDir(p); dir != ""; dir = pathDir(dir) {
+			if t.After(dirTimes[dir]) {
+				dirTimes[dir] = t
+			}
+		}
+	}
+	for dir, t := range dirTimes {
+		d.modTimes[dir] = t
+	}
+}
+
+// commitTimes looks up the commit times of a group. Paths are relative to
+// the top-level repository, while the group's repository is asked for paths
+// relative to itself.
+func (d *Downloader) commitTimes(group *timestampGroup) (map[string]time.Time, error) {
+	prefix := ""
+	if group.repo.path != "" {
+		prefix = group.repo.path + "/"
+	}
+
+	lookups := []string{""}
+	if d.Timestamps == TimestampsFile {
+		lookups = make([]string, len(group.paths))
+		for i, p := range group.paths {
+			lookups[i] = strings.TrimPrefix(p, prefix)
+		}
+	}
+
+	repo := group.repo.target
+	times, err := group.repo.source.CommitTimes(repo.Owner, repo.Repo, repo.Commit, lookups)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]time.Time, len(group.paths))
+	for _, p := range group.paths {
+		lookup := ""
+		if d.Timestamps == TimestampsFile {
+			lookup = strings.TrimPrefix(p, prefix)
+		}
+		if t, ok := times[lookup]; ok {
+			byPath[p] = t
+		}
+	}
+	return byPath, nil
+}
+
+// modTime returns the modification time looked up for content, or the zero
+// time when there is none
+func (d *Downloader) modTime(content forge.Content) time.Time {
+	return d.modTimes[content.Path]
+}
+
+// applyModTime sets the modification time of a downloaded file
+func (d *Downloader) applyModTime(content forge.Content, filePath string) error {
+	t := d.modTime(content)
+	if t.IsZero() {
+		return nil
+	}
+
+	if err := os.Chtimes(filePath, t, t); err != nil {
+		return fmt.Errorf("failed to set modification time: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// Downloads into a directory are written to a partial file next to their
+// destination, e.g. .gitdig-model.bin.part, which is renamed into place once
+// complete. A transfer that breaks off leaves the partial file behind, so
+// that the next attempt, in the same run or a later one, can resume it with
+// a Range request. A record next to it, .gitdig-model.bin.part.json, tells
+// what the partial file holds.
+const (
+	partSuffix   = ".part"
+	recordSuffix = partSuffix + ".json"
+)
+
+// partialRecord describes the content of a partial file
+type partialRecord struct {
+	// ID identifies the file being downloaded: its blob ID, or the sha256
+	// oid of an LFS object
+	ID string `json:"id"`
+	// ETag is the entity tag the server sent the file with
+	ETag string `json:"etag"`
+}
+
+// partialPath returns the location of the partial file of filePath
+func partialPath(filePath string) string {
+	dir, base := filepath.Split(filePath)
+	return filepath.Join(dir, tempPrefix+base+partSuffix)
+}
+
+// isPartialFile reports whether name is that of a partial file or its record
+func isPartialFile(name string) bool {
+	return strings.HasPrefix(name, tempPrefix) && (strings.HasSuffix(name, partSuffix) || strings.HasSuffix(name, recordSuffix))
+}
+
+// resumePoint returns where the download of id into the partial file at
+// partPath stopped, or the zero Point when there is nothing to resume
+func resumePoint(partPath, id string) resume.Point {
+	if id == "" {
+		return resume.Point{}
+	}
+
+	data, err := os.ReadFile(partPath + ".json")
+	if err != nil {
+		return resume.Point{}
+	}
+	var record partialRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.ID != id || record.ETag == "" {
+		return resume.Point{}
+	}
+
+	stat, err := os.Stat(partPath)
+	if err != nil || !stat.Mode().IsRegular() {
+		return resume.Point{}
+	}
+
+	return resume.Point{Offset: stat.Size(), ETag: record.ETag}
+}
+
+// resumable reports whether the partial file at partPath holds data that a
+// later download can resume from
+func resumable(partPath string) bool {
+	stat, err := os.Stat(partPath)
+	if err != nil || stat.Size() == 0 {
+		return false
+	}
+	_, err = os.Stat(partPath + ".json")
+	return err == nil
+}
+
+// startPartial prepares the partial file f for the body of resp: bytes past
+// the offset it resumes at are cut off, and a download that starts over is
+// recorded under id, if the server allows resuming it
+func startPartial(f *os.File, id string, resp *resume.Response) error {
+	if err := f.Truncate(resp.Offset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(resp.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	if resp.Offset > 0 {
+		return nil
+	}
+
+	recordPath := f.Name() + ".json"
+	if id == "" || resp.ETag == "" {
+		if err := os.Remove(recordPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(partialRecord{ID: id, ETag: resp.ETag})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(recordPath, data, 0644)
+}
+
+// removePartial removes a partial file and its record
+func removePartial(partPath string) {
+	os.Remove(partPath)
+	os.Remove(partPath + ".json")
+}
@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+func TestPartialRecord(t *testing.T) {
+	partPath := partialPath(filepath.Join(t.TempDir(), "model.bin"))
+	if name := filepath.Base(partPath); name != ".gitdig-model.bin.part" || !isPartialFile(name) || !isPartialFile(name+".json") {
+		t.Fatalf("partialPath = %s, want a partial file name", partPath)
+	}
+
+	f, err := os.Create(partPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A download that starts over is recorded with its ETag
+	if err := startPartial(f, "blob1", &resume.Response{ETag: `"v1"`}); err != nil {
+		t.Fatalf("startPartial failed: %v", err)
+	}
+	if _, err := f.WriteString("01234"); err != nil {
+		t.Fatal(err)
+	}
+
+	if from := resumePoint(partPath, "blob1"); from.Offset != 5 || from.ETag != `"v1"` {
+		t.Errorf("resumePoint = %+v, want offset 5 and the recorded ETag", from)
+	}
+	if from := resumePoint(partPath, "blob2"); from != (resume.Point{}) {
+		t.Errorf("resumePoint for another file = %+v, want nothing to resume", from)
+	}
+	if !resumable(partPath) {
+		t.Error("resumable = false for a recorded partial file")
+	}
+
+	// Resuming cuts off anything past the offset the server resumed at
+	if err := startPartial(f, "blob1", &resume.Response{ETag: `"v1"`, Offset: 3}); err != nil {
+		t.Fatalf("startPartial failed: %v", err)
+	}
+	if from := resumePoint(partPath, "blob1"); from.Offset != 3 {
+		t.Errorf("resumePoint after resuming at 3 = %+v, want offset 3", from)
+	}
+
+	// Without an ETag the download cannot be resumed and the record goes
+	if err := startPartial(f, "blob1", &resume.Response{}); err != nil {
+		t.Fatalf("startPartial failed: %v", err)
+	}
+	if _, err := os.Stat(partPath + ".json"); !os.IsNotExist(err) {
+		t.Errorf("record left behind for a download without an ETag: %v", err)
+	}
+	if resumable(partPath) {
+		t.Error("resumable = true without a record")
+	}
+
+	removePartial(partPath)
+	if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+		t.Errorf("removePartial left the partial file: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/display"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/source"
+)
+
+// Submodule policies
+const (
+	// SubmodulesSkip leaves submodules out silently
+	SubmodulesSkip = "skip"
+	// SubmodulesWarn leaves submodules out with a warning
+	SubmodulesWarn = "warn"
+	// SubmodulesRecurse downloads each submodule's repository at its pinned commit
+	SubmodulesRecurse = "recurse"
+)
+
+// submodule is a submodule repository downloaded along with its parent
+type submodule struct {
+	// path is the location of the submodule below the top-level repository
+	path        string
+	target      forge.DownloadTarget
+	source      source.Provider
+	lfsEndpoint lfs.Endpoint
+}
+
+// expandSubmodules applies the submodule policy to entries, the listing of
+// repo. When repo is itself a submodule, its entries are moved below prefix.
+// With the recurse policy, each submodule becomes a directory holding the
+// listing of its repository at the pinned commit.
+func (d *Downloader) expandSubmodules(repo forge.DownloadTarget, provider source.Provider, prefix string, entries []forge.Content, visited map[string]bool) []forge.Content {
+	var gitmodules map[string]string
+	var expanded []forge.Content
+
+	for _, content := range entries {
+		repoPath := content.Path
+		if prefix != "" {
+			content.Path = prefix + "/" + content.Path
+		}
+
+		if content.Type != "submodule" {
+			expanded = append(expanded, content)
+			continue
+		}
+
+		if d.Submodules == SubmodulesSkip || !d.Recursive {
+			d.unlisted[content.Path] = true
+			continue
+		}
+		if d.Submodules != SubmodulesRecurse {
+			display.Warning("Warning: Skipping submodule %s, use -submodules recurse to download it\n", content.Path)
+			d.unlisted[content.Path] = true
+			continue
+		}
+
+		if gitmodules == nil {
+			gitmodules = d.readGitmodules(repo, provider)
+		}
+
+		children, err := d.listSubmodule(repo, content, gitmodules[repoPath], visited)
+		if err != nil {
+			display.Warning("Warning: Skipping submodule %s: %v\n", content.Path, err)
+			d.unlisted[content.Path] = true
+			continue
+		}
+
+		content.Type = "dir"
+		expanded = append(expanded, content)
+		expanded = append(expanded, children...)
+	}
+
+	return expanded
+}
+
+// listSubmodule lists the repository of a submodule of parent at its pinned
+// commit. submoduleURL is the URL recorded in .gitmodules, if any.
+func (d *Downloader) listSubmodule(parent forge.DownloadTarget, content forge.Content, submoduleURL string, visited map[string]bool) ([]forge.Content, error) {
+	if content.SubmoduleURL != "" {
+		submoduleURL = content.SubmoduleURL
+	}
+	if submoduleURL == "" {
+		return nil, errors.New("no URL found in .gitmodules")
+	}
+	if content.SHA == "" {
+		return nil, errors.New("the listing does not report the pinned commit")
+	}
+
+	target, err := source.ParseSubmoduleURL(parent, submoduleURL, d.TokenHost)
+	if err != nil {
+		return nil, err
+	}
+	target.Branch = content.SHA
+	target.Commit = content.SHA
+
+	key := target.Host.APIURL + "/" + target.Owner + "/" + target.Repo + "@" + target.Commit
+	if visited[key] {
+		return nil, fmt.Errorf("%s/%s includes itself", target.Owner, target.Repo)
+	}
+	visited[key] = true
+	defer delete(visited, key)
+
+	provider, err := source.New(target, source.Token(target, d.Token, d.TokenHost))
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Verbose {
+		display.Info("Listing submodule %s: %s/%s @ %s\n", content.Path, target.Owner, target.Repo, refLabel(target))
+	}
+
+	entries, err := d.listEntries(provider, target.Owner, target.Repo, target.Commit, "")
+	if err != nil {
+		return nil, err
+	}
+
+	d.submodules = append(d.submodules, submodule{
+		path:        content.Path,
+		target:      target,
+		source:      provider,
+		lfsEndpoint: provider.LFSEndpoint(target.Owner, target.Repo),
+	})
+
+	return d.expandSubmodules(target, provider, content.Path, entries, visited), nil
+}
+
+// readGitmodules reads the submodule URLs of repo from its .gitmodules file.
+// A missing or unreadable file yields no URLs.
+func (d *Downloader) readGitmodules(repo forge.DownloadTarget, provider source.Provider) map[string]string {
+	file, err := provider.Stat(repo.Owner, repo.Repo, repo.Commit, ".gitmodules")
+	if err != nil || file.Type != "file" {
+		return map[string]string{}
+	}
+
+	data, err := source.ReadFile(provider, file)
+	if err != nil {
+		return map[string]string{}
+	}
+
+	return source.ParseGitmodules(data)
+}
+
+// sourceFor returns the provider and LFS server of the repository holding the
+// file at p, which is a submodule's repository when p lies below one
+func (d *Downloader) sourceFor(p string) (source.Provider, lfs.Endpoint) {
+	repo := d.repositoryOf(p)
+	return repo.source, repo.lfsEndpoint
+}
+
+// repositoryOf returns the repository holding the file at p. The top-level
+// repository is returned with an empty path.
+func (d *Downloader) repositoryOf(p string) submodule {
+	repo := submodule{target: d.target, source: d.source, lfsEndpoint: d.lfsEndpoint}
+	for _, sub := range d.submodules {
+		if strings.HasPrefix(p, sub.path+"/") && len(sub.path) > len(repo.path) {
+			repo = sub
+		}
+	}
+	return repo
+}
@@ -0,0 +1,242 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/display"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/source"
+)
+
+// maxSymlinkHops bounds the length of symlink chains followed when
+// dereferencing, like the kernel's limit on nested links
+const maxSymlinkHops = 40
+
+// resolveSymlinks reads the target of every symlink among entries and leaves
+// out links that point outside dirPath, the root of the download. When
+// dereferencing, each remaining link is replaced by a copy of the file or
+// directory it points to.
+func (d *Downloader) resolveSymlinks(entries []forge.Content, dirPath string) []forge.Content {
+	byPath := make(map[string]forge.Content, len(entries))
+	for _, content := range entries {
+		byPath[content.Path] = content
+	}
+
+	var resolved []forge.Content
+	for _, content := range entries {
+		if content.Type != "symlink" {
+			resolved = append(resolved, content)
+			continue
+		}
+
+		dest, err := d.followSymlink(content, byPath, dirPath)
+		if err != nil {
+			display.Warning("Warning: Skipping symlink %s: %v\n", content.Path, err)
+			continue
+		}
+
+		if !d.Dereference {
+			resolved = append(resolved, byPath[content.Path])
+			continue
+		}
+
+		copies, err := d.dereference(content, dest, entries)
+		if err != nil {
+			display.Warning("Warning: Skipping symlink %s: %v\n", content.Path, err)
+			continue
+		}
+		resolved = append(resolved, copies...)
+	}
+
+	return resolved
+}
+
+// followSymlink checks that link points inside dirPath and, when
+// dereferencing, follows it through any further links to the entry it
+// finally points to. Link targets are read into byPath as they are needed.
+func (d *Downloader) followSymlink(link forge.Content, byPath map[string]forge.Content, dirPath string) (forge.Content, error) {
+	current := link
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		if current.Target == "" {
+			target, err := d.readSymlink(current)
+			if err != nil {
+				return forge.Content{}, err
+			}
+			current.Target = target
+			byPath[current.Path] = current
+		}
+
+		targetPath, err := resolveTarget(current, byPath, dirPath)
+		if err != nil {
+			return forge.Content{}, err
+		}
+
+		if !d.Dereference {
+			return forge.Content{}, nil
+		}
+
+		next, ok := byPath[targetPath]
+		if !ok {
+			return forge.Content{}, fmt.Errorf("target %s was not found", current.Target)
+		}
+		if next.Type != "symlink" {
+			return next, nil
+		}
+		current = next
+	}
+
+	return forge.Content{}, errors.New("too many levels of symbolic links")
+}
+
+// readSymlink fetches the target of a symlink, which the providers serve as
+// the content of the link
+func (d *Downloader) readSymlink(link forge.Content) (string, error) {
+	provider, _ := d.sourceFor(link.Path)
+	data, err := source.ReadFile(provider, link)
+	if err != nil {
+		return "", fmt.Errorf("failed to read link target: %w", err)
+	}
+
+	target := string(data)
+	if target == "" || strings.ContainsRune(target, 0) {
+		return "", errors.New("invalid link target")
+	}
+
+	return target, nil
+}
+
+// dereference copies dest, the entry link points to, to the location of the
+// link. Directories are copied with everything listed below them, except
+// for further symlinks.
+func (d *Downloader) dereference(link, dest forge.Content, entries []forge.Content) ([]forge.Content, error) {
+	if dest.Type != "dir" {
+		dest.Name, dest.Path = link.Name, link.Path
+		return []forge.Content{dest}, nil
+	}
+
+	if strings.HasPrefix(link.Path, dest.Path+"/") {
+		return nil, fmt.Errorf("target %s contains the link itself", link.Target)
+	}
+
+	copies := []forge.Content{{Name: link.Name, Path: link.Path, Type: "dir"}}
+	for _, content := range entries {
+		rel, ok := strings.CutPrefix(content.Path, dest.Path+"/")
+		if !ok || content.Type == "symlink" {
+			continue
+		}
+		content.Path = link.Path + "/" + rel
+		copies = append(copies, content)
+	}
+
+	return copies, nil
+}
+
+// resolveTarget resolves the target of link against the listed entries one
+// path component at a time, the way the file system would, and returns the
+// repository path it points to. A step that leaves dirPath fails, and so
+// does a target that passes through another symlink, since where the rest
+// of the target leads then depends on that link.
+func resolveTarget(link forge.Content, byPath map[string]forge.Content, dirPath string) (string, error) {
+	if path.IsAbs(link.Target) {
+		return "", fmt.Errorf("target %s is outside the downloaded directory", link.Target)
+	}
+
+	var components []string
+	for _, component := range strings.Split(link.Target, "/") {
+		if component != "" && component != "." {
+			components = append(components, component)
+		}
+	}
+
+	root := strings.Trim(dirPath, "/")
+	current := parentPath(link.Path)
+	for i, component := range components {
+		if component == ".." {
+			if current == root {
+				return "", fmt.Errorf("target %s is outside the downloaded directory", link.Target)
+			}
+			current = parentPath(current)
+			continue
+		}
+
+		if current == "" {
+			current = component
+		} else {
+			current += "/" + component
+		}
+		if entry, ok := byPath[current]; ok && entry.Type == "symlink" && i < len(components)-1 {
+			return "", fmt.Errorf("target %s passes through symlink %s", link.Target, current)
+		}
+	}
+
+	return current, nil
+}
+
+// parentPath returns the directory containing the repository path p, or ""
+// at the top of the repository
+func parentPath(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// createSymlink recreates a symlink on disk, or as a symlink entry in the
+// archive
+func (d *Downloader) createSymlink(content forge.Content, localPath string) {
+	var err error
+	if d.ArchiveOutput {
+		err = d.archive.AddSymlink(content.Target, content.Path, d.modTime(content))
+	} else {
+		err = writeSymlink(content.Target, localPath)
+	}
+
+	d.Stats.Lock()
+	defer d.Stats.Unlock()
+
+	if err != nil {
+		display.Error("Failed: %s (%v)\n", content.Path, err)
+		d.Stats.Failures++
+		return
+	}
+
+	if d.Verbose {
+		display.Success("Linked: %s -> %s\n", content.Path, content.Target)
+	}
+	d.Stats.Links++
+}
+
+// writeSymlink creates a symlink at localPath, replacing whatever is there
+func writeSymlink(target, localPath string) error {
+	if existing, err := os.Readlink(localPath); err == nil && existing == filepath.FromSlash(target) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The link is created under a temporary name and renamed over
+	// whatever is there, so that localPath never goes missing
+	tmp, err := createTemp(localPath, 0666)
+	if err != nil {
+		return err
+	}
+	discardTemp(tmp)
+
+	if err := os.Symlink(filepath.FromSlash(target), tmp.Name()); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace existing file: %w", err)
+	}
+
+	return nil
+}
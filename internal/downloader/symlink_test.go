@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+func TestResolveTarget(t *testing.T) {
+	entries := []forge.Content{
+		{Path: "a", Type: "dir"},
+		{Path: "a/deep", Type: "dir"},
+		{Path: "a/deep/file", Type: "file"},
+		{Path: "a/deep/up", Type: "symlink", Target: "../.."},
+		{Path: "a/deep/sibling", Type: "symlink", Target: "../other"},
+		{Path: "a/other", Type: "file"},
+	}
+	byPath := make(map[string]forge.Content, len(entries))
+	for _, entry := range entries {
+		byPath[entry.Path] = entry
+	}
+
+	tests := []struct {
+		path    string
+		target  string
+		dirPath string
+		want    string
+		wantErr string
+	}{
+		{path: "a/link", target: "deep/file", dirPath: "a", want: "a/deep/file"},
+		{path: "a/deep/link", target: "./../other", dirPath: "a", want: "a/other"},
+		{path: "a/deep/link", target: "..", dirPath: "a", want: "a"},
+		{path: "a/deep/link", target: "up", dirPath: "a", want: "a/deep/up"},
+		{path: "top", target: "a/deep/", dirPath: "", want: "a/deep"},
+		{path: "a/deep/link", target: "../..", dirPath: "a", wantErr: "outside"},
+		{path: "a/deep/link", target: "../../a/other", dirPath: "a", wantErr: "outside"},
+		{path: "top", target: "../x", dirPath: "", wantErr: "outside"},
+		{path: "a/link", target: "/etc/passwd", dirPath: "a", wantErr: "outside"},
+		{path: "a/c", target: "deep/up/..", dirPath: "a", wantErr: "passes through symlink a/deep/up"},
+		{path: "a/c", target: "deep/sibling/x", dirPath: "", wantErr: "passes through symlink a/deep/sibling"},
+	}
+
+	for _, tt := range tests {
+		link := forge.Content{Path: tt.path, Type: "symlink", Target: tt.target}
+		got, err := resolveTarget(link, byPath, tt.dirPath)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolveTarget(%s -> %s) = %q, %v, want an error containing %q", tt.path, tt.target, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveTarget(%s -> %s) = %q, %v, want %q", tt.path, tt.target, got, err, tt.want)
+		}
+	}
+}
+
+func TestFollowSymlink(t *testing.T) {
+	byPath := map[string]forge.Content{
+		"a/file":   {Path: "a/file", Type: "file"},
+		"a/first":  {Path: "a/first", Type: "symlink", Target: "second"},
+		"a/second": {Path: "a/second", Type: "symlink", Target: "file"},
+		"a/loop":   {Path: "a/loop", Type: "symlink", Target: "loop"},
+		"a/up":     {Path: "a/up", Type: "symlink", Target: ".."},
+	}
+	d := &Downloader{Dereference: true}
+
+	dest, err := d.followSymlink(byPath["a/first"], byPath, "a")
+	if err != nil || dest.Path != "a/file" {
+		t.Errorf("followSymlink(a/first) = %+v, %v, want a/file", dest, err)
+	}
+
+	if _, err := d.followSymlink(byPath["a/loop"], byPath, "a"); err == nil || !strings.Contains(err.Error(), "too many levels") {
+		t.Errorf("followSymlink(a/loop) = %v, want a loop error", err)
+	}
+
+	if _, err := d.followSymlink(byPath["a/up"], byPath, "a"); err == nil {
+		t.Error("followSymlink(a/up) succeeded, want an error for a target outside the directory")
+	}
+}
@@ -0,0 +1,238 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/display"
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// syncPlan holds the local paths a sync removes, relative to the managed
+// directory and in slash form
+type syncPlan struct {
+	root  string
+	files []string
+	// dirs are the directories that are removed once they are empty
+	dirs []string
+}
+
+// planSync finds the local files of a directory target that no longer
+// exist upstream. When the directory holds a lockfile from an earlier
+// download, only the files recorded in it are managed, so that files added
+// locally are kept. Otherwise the whole directory is managed, except for
+// the lockfile itself and temporary and partial files, which are cleaned up
+// separately. Either way, files the include and exclude patterns leave out
+// and submodules that were not listed are never managed.
+func (d *Downloader) planSync(target forge.DownloadTarget, entries []forge.Content) (*syncPlan, error) {
+	root := target.LocalDir
+	if err := checkSyncRoot(root); err != nil {
+		return nil, err
+	}
+
+	plan := &syncPlan{root: root}
+	if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+		return plan, nil
+	}
+
+	expected := make(map[string]bool)
+	for _, content := range entries {
+		expected[relativePath(content.Path, target.DirPath)] = true
+	}
+
+	// repoPath maps a path below root back to its path in the repository
+	dirPath := strings.Trim(target.DirPath, "/")
+	repoPath := func(rel string) string {
+		return path.Join(dirPath, rel)
+	}
+	managed := func(rel string) bool {
+		return !d.inUnlisted(repoPath(rel)) && d.Filter.Allows(repoPath(rel))
+	}
+
+	emptyDirs := make(map[string]bool)
+
+	if lock, err := readLockFile(d.lockPath(target)); err == nil {
+		for _, file := range lock.Files {
+			rel := relativePath(file.Path, lock.Path)
+			if expected[rel] || !managed(rel) {
+				continue
+			}
+			info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+			if err != nil || info.IsDir() {
+				continue
+			}
+			plan.files = append(plan.files, rel)
+
+			// Directories left empty by the removal go as well
+			for dir := pathDir(rel); dir != ""; dir = pathDir(dir) {
+				emptyDirs[dir] = true
+			}
+		}
+	} else {
+		err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == root {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			switch {
+			case entry.IsDir() && d.inUnlisted(repoPath(rel)):
+				return fs.SkipDir
+			case entry.IsDir():
+				emptyDirs[rel] = true
+			case rel == lockFileName || expected[rel] || !managed(rel) || isTempFile(entry.Name()) || isPartialFile(entry.Name()):
+			default:
+				plan.files = append(plan.files, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+
+	for dir := range emptyDirs {
+		if !expected[dir] {
+			plan.dirs = append(plan.dirs, dir)
+		}
+	}
+	sort.Strings(plan.files)
+
+	// Deepest directories first, so that parents can become empty
+	sort.Slice(plan.dirs, func(i, j int) bool {
+		return strings.Count(plan.dirs[i], "/") > strings.Count(plan.dirs[j], "/")
+	})
+
+	return plan, nil
+}
+
+// inUnlisted reports whether repoPath is a submodule left out of the
+// listing, or lies inside one
+func (d *Downloader) inUnlisted(repoPath string) bool {
+	for p := repoPath; p != "." && p != "/"; p = path.Dir(p) {
+		if d.unlisted[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// pathDir returns the parent of a slash-separated relative path, or an empty
+// string at the top level
+func pathDir(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
+
+// checkSyncRoot refuses to manage the filesystem root or the home directory
+func checkSyncRoot(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	home, _ := os.UserHomeDir()
+	if abs == filepath.Dir(abs) || (home != "" && abs == filepath.Clean(home)) {
+		return fmt.Errorf("refusing to sync %s, choose a dedicated output directory with -o", abs)
+	}
+	return nil
+}
+
+// confirmSync lists the files a sync would remove and asks whether to go
+// ahead, unless removals were confirmed up front with -yes
+func (d *Downloader) confirmSync(plan *syncPlan) bool {
+	if len(plan.files) == 0 {
+		return true
+	}
+
+	display.Warning("Files removed upstream (%d):\n", len(plan.files))
+	for _, rel := range plan.files {
+		display.Warning("  - %s\n", filepath.Join(plan.root, filepath.FromSlash(rel)))
+	}
+
+	if d.AssumeYes {
+		return true
+	}
+
+	answer, _ := display.Prompt(fmt.Sprintf("Remove %d files? [y/N]: ", len(plan.files)))
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// applySync removes the planned files and the directories they leave empty.
+// Every path is checked to lie inside the managed directory, without
+// following symlinks out of it.
+func (d *Downloader) applySync(plan *syncPlan) {
+	realRoot, err := filepath.EvalSymlinks(plan.root)
+	if err != nil {
+		display.Error("Error resolving %s: %v\n", plan.root, err)
+		return
+	}
+
+	for _, rel := range plan.files {
+		p := filepath.Join(plan.root, filepath.FromSlash(rel))
+		if err := checkInside(realRoot, p); err != nil {
+			display.Error("Not removing %s: %v\n", p, err)
+			d.Stats.Failures++
+			continue
+		}
+
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			display.Error("Error removing %s: %v\n", p, err)
+			d.Stats.Failures++
+			continue
+		}
+		if d.Verbose {
+			display.Info("Removed: %s\n", p)
+		}
+		d.Stats.Removed++
+	}
+
+	for _, rel := range plan.dirs {
+		p := filepath.Join(plan.root, filepath.FromSlash(rel))
+		if checkInside(realRoot, p) != nil {
+			continue
+		}
+
+		children, err := os.ReadDir(p)
+		if err != nil || len(children) > 0 {
+			continue
+		}
+		if err := os.Remove(p); err == nil && d.Verbose {
+			display.Info("Removed empty directory: %s\n", p)
+		}
+	}
+}
+
+// checkInside verifies that p lies inside realRoot once the symlinks of its
+// parent directories are resolved. p itself may be a symlink; removing it
+// does not touch its target.
+func checkInside(realRoot, p string) error {
+	parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(realRoot, filepath.Join(parent, filepath.Base(p)))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.New("outside the managed directory")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/glob"
+)
+
+// writeTree creates the given files below root, with directories as needed
+func writeTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+	for _, name := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	writeTree(t, root,
+		"keep.md", "old.md", "gone/deep/old.txt", "notes.txt", "vendor/lib/a.go",
+		lockFileName, ".gitdig-x.md.part",
+	)
+
+	d := &Downloader{
+		Filter:   glob.Filter{Exclude: []string{"**/*.txt"}},
+		unlisted: map[string]bool{"docs/vendor/lib": true},
+	}
+	target := forge.DownloadTarget{DirPath: "docs", LocalDir: root}
+	entries := []forge.Content{
+		{Path: "docs/keep.md", Type: "file"},
+		{Path: "docs/vendor", Type: "dir"},
+	}
+
+	plan, err := d.planSync(target, entries)
+	if err != nil {
+		t.Fatalf("planSync failed: %v", err)
+	}
+
+	// Excluded files, unlisted submodules, the lockfile and partial files
+	// are left alone
+	wantFiles := []string{"old.md"}
+	if !slices.Equal(plan.files, wantFiles) {
+		t.Errorf("planSync files = %v, want %v", plan.files, wantFiles)
+	}
+	wantDirs := []string{"gone/deep", "gone"}
+	if !slices.Equal(plan.dirs, wantDirs) {
+		t.Errorf("planSync dirs = %v, want %v", plan.dirs, wantDirs)
+	}
+}
+
+func TestPlanSyncLockFile(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+	writeTree(t, root, "keep.md", "old/a.md", "mine.md")
+
+	d := &Downloader{}
+	target := forge.DownloadTarget{DirPath: "docs", LocalDir: root}
+	lock := lockFile{Version: lockFileVersion, Path: "docs", Files: []lockedFile{{Path: "docs/keep.md"}, {Path: "docs/old/a.md"}}}
+	if err := writeLockFile(d.lockPath(target), lock); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := d.planSync(target, []forge.Content{{Path: "docs/keep.md", Type: "file"}})
+	if err != nil {
+		t.Fatalf("planSync failed: %v", err)
+	}
+
+	// Only files recorded in the lockfile are managed
+	if !slices.Equal(plan.files, []string{"old/a.md"}) || !slices.Equal(plan.dirs, []string{"old"}) {
+		t.Errorf("planSync = %v, %v, want old/a.md and old", plan.files, plan.dirs)
+	}
+}
+
+func TestCheckSyncRoot(t *testing.T) {
+	if err := checkSyncRoot("/"); err == nil {
+		t.Error("checkSyncRoot(/) succeeded")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := checkSyncRoot(home); err == nil {
+			t.Errorf("checkSyncRoot(%s) succeeded", home)
+		}
+	}
+	if err := checkSyncRoot(t.TempDir()); err != nil {
+		t.Errorf("checkSyncRoot of a temporary directory failed: %v", err)
+	}
+}
+
+func TestCheckInside(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeTree(t, root, "dir/file")
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "dir"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rel    string
+		inside bool
+	}{
+		{rel: "dir/file", inside: true},
+		{rel: "alias/file", inside: true},
+		{rel: "escape", inside: true},
+		{rel: "escape/file", inside: false},
+		{rel: "..", inside: false},
+		{rel: ".", inside: false},
+	}
+
+	for _, tt := range tests {
+		err := checkInside(realRoot, filepath.Join(root, tt.rel))
+		if (err == nil) != tt.inside {
+			t.Errorf("checkInside(%s) = %v, want inside %v", tt.rel, err, tt.inside)
+		}
+	}
+}
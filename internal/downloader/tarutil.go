@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TarWriter handles creating tarballs, uncompressed or compressed with gzip
+// or zstd. Like ZipWriter, it is safe for concurrent use.
+type TarWriter struct {
+	// tarFile is a temporary file that is moved to outputPath on Close
+	tarFile    *os.File
+	outputPath string
+	// compressor compresses the tar stream, nil for a plain tarball
+	compressor io.WriteCloser
+	writer     *tar.Writer
+	created    time.Time
+	mu         sync.Mutex
+}
+
+// NewTarWriter creates a new tarball writer for the given tar format. The
+// tarball only appears at outputPath once it is closed.
+func NewTarWriter(outputPath, format string) (*TarWriter, error) {
+	tarFile, err := createTemp(outputPath, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tar file: %w", err)
+	}
+
+	t := &TarWriter{
+		tarFile:    tarFile,
+		outputPath: outputPath,
+		created:    time.Now(),
+	}
+
+	var w io.Writer = tarFile
+	switch format {
+	case FormatTarGz:
+		t.compressor = gzip.NewWriter(tarFile)
+		w = t.compressor
+	case FormatTarZst:
+		encoder, err := zstd.NewWriter(tarFile)
+		if err != nil {
+			discardTemp(tarFile)
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		t.compressor = encoder
+		w = t.compressor
+	}
+	t.writer = tar.NewWriter(w)
+
+	return t, nil
+}
+
+// AddFile adds a regular file to the tarball, copying size bytes from r.
+// Files without a known mode get the default permissions 0644.
+func (t *TarWriter) AddFile(r io.Reader, filePath string, size int64, perm os.FileMode, modified time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if perm == 0 {
+		perm = 0644
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filePath,
+		Size:     size,
+		Mode:     int64(perm),
+		ModTime:  t.stamp(modified),
+	}
+	if err := t.writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to create tar entry: %w", err)
+	}
+
+	if _, err := io.CopyN(t.writer, r, size); err != nil {
+		return fmt.Errorf("failed to write tar entry: %w", err)
+	}
+
+	return nil
+}
+
+// AddSymlink adds a symlink entry pointing to target
+func (t *TarWriter) AddSymlink(target, filePath string, modified time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	header := &tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     filePath,
+		Linkname: target,
+		Mode:     0777,
+		ModTime:  t.stamp(modified),
+	}
+	if err := t.writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to create tar entry: %w", err)
+	}
+
+	return nil
+}
+
+// CreateDirEntry adds a directory entry to the tarball
+func (t *TarWriter) CreateDirEntry(dirPath string, modified time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	header := &tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     strings.TrimSuffix(dirPath, "/") + "/",
+		Mode:     0755,
+		ModTime:  t.stamp(modified),
+	}
+	if err := t.writer.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to create directory entry: %w", err)
+	}
+
+	return nil
+}
+
+// Close finalizes the tarball and moves it into place
+func (t *TarWriter) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.writer.Close()
+	if err == nil && t.compressor != nil {
+		err = t.compressor.Close()
+	}
+	if err != nil {
+		discardTemp(t.tarFile)
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	if err := commitTemp(t.tarFile, t.outputPath); err != nil {
+		return fmt.Errorf("failed to save tar file: %w", err)
+	}
+
+	return nil
+}
+
+// stamp returns the modification time of an entry, the creation time of
+// the tarball when it has none
+func (t *TarWriter) stamp(modified time.Time) time.Time {
+	if modified.IsZero() {
+		return t.created
+	}
+	return modified
+}
@@ -3,40 +3,59 @@ package downloader
 import (
 	"archive/zip"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
-// ZipWriter handles creating zip archives
+// ZipWriter handles creating zip archives. It is safe for concurrent use;
+// entries are written one at a time.
 type ZipWriter struct {
-	zipFile *os.File
-	writer  *zip.Writer
-	baseDir string
+	// zipFile is a temporary file that is moved to outputPath on Close
+	zipFile    *os.File
+	outputPath string
+	writer     *zip.Writer
+	baseDir    string
+	created    time.Time
+	mu         sync.Mutex
 }
 
-// NewZipWriter creates a new zip archive writer
+// NewZipWriter creates a new zip archive writer. The archive only appears at
+// outputPath once it is closed.
 func NewZipWriter(outputPath string, baseDir string) (*ZipWriter, error) {
-	zipFile, err := os.Create(outputPath)
+	zipFile, err := createTemp(outputPath, 0666)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create zip file: %w", err)
 	}
 
 	return &ZipWriter{
-		zipFile: zipFile,
-		writer:  zip.NewWriter(zipFile),
-		baseDir: baseDir,
+		zipFile:    zipFile,
+		outputPath: outputPath,
+		writer:     zip.NewWriter(zipFile),
+		baseDir:    baseDir,
+		created:    time.Now(),
 	}, nil
 }
 
-// AddFile adds a file to the zip archive
-func (z *ZipWriter) AddFile(data []byte, filePath string) error {
+// AddFile adds a file to the zip archive, copying its content from r. A
+// non-zero perm is stored as the entry's Unix permission bits.
+func (z *ZipWriter) AddFile(r io.Reader, filePath string, size int64, perm os.FileMode, modified time.Time) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
 	// Create a relative path for the file in the zip
 	relPath := strings.TrimPrefix(filePath, z.baseDir)
 	relPath = strings.TrimPrefix(relPath, "/")
 
 	header := &zip.FileHeader{
-		Name:   relPath,
-		Method: zip.Deflate,
+		Name:     relPath,
+		Method:   zip.Deflate,
+		Modified: z.stamp(modified),
+	}
+	if perm != 0 {
+		header.SetMode(perm)
 	}
 
 	writer, err := z.writer.CreateHeader(header)
@@ -44,7 +63,7 @@ func (z *ZipWriter) AddFile(data []byte, filePath string) error {
 		return fmt.Errorf("failed to create zip entry: %w", err)
 	}
 
-	_, err = writer.Write(data)
+	_, err = io.Copy(writer, r)
 	if err != nil {
 		return fmt.Errorf("failed to write zip entry: %w", err)
 	}
@@ -52,21 +71,56 @@ func (z *ZipWriter) AddFile(data []byte, filePath string) error {
 	return nil
 }
 
-// Close finalizes the zip archive
+// AddSymlink adds a symlink entry pointing to target. The link is stored
+// with Unix mode bits, the way zip and unzip store symlinks.
+func (z *ZipWriter) AddSymlink(target, filePath string, modified time.Time) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	relPath := strings.TrimPrefix(filePath, z.baseDir)
+	relPath = strings.TrimPrefix(relPath, "/")
+
+	header := &zip.FileHeader{
+		Name:     relPath,
+		Method:   zip.Store,
+		Modified: z.stamp(modified),
+	}
+	header.SetMode(os.ModeSymlink | 0777)
+
+	writer, err := z.writer.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %w", err)
+	}
+
+	if _, err := io.WriteString(writer, target); err != nil {
+		return fmt.Errorf("failed to write zip entry: %w", err)
+	}
+
+	return nil
+}
+
+// Close finalizes the zip archive and moves it into place
 func (z *ZipWriter) Close() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
 	if err := z.writer.Close(); err != nil {
+		discardTemp(z.zipFile)
 		return fmt.Errorf("failed to close zip writer: %w", err)
 	}
 
-	if err := z.zipFile.Close(); err != nil {
-		return fmt.Errorf("failed to close zip file: %w", err)
+	if err := commitTemp(z.zipFile, z.outputPath); err != nil {
+		return fmt.Errorf("failed to save zip file: %w", err)
 	}
 
 	return nil
 }
 
 // CreateDirEntry adds a directory entry to the zip
-func (z *ZipWriter) CreateDirEntry(dirPath string) error {
+func (z *ZipWriter) CreateDirEntry(dirPath string, modified time.Time) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
 	relPath := strings.TrimPrefix(dirPath, z.baseDir)
 	relPath = strings.TrimPrefix(relPath, "/")
 
@@ -76,9 +130,11 @@ func (z *ZipWriter) CreateDirEntry(dirPath string) error {
 	}
 
 	header := &zip.FileHeader{
-		Name:   relPath,
-		Method: zip.Store, // Directories are just entries, no compression needed
+		Name:     relPath,
+		Method:   zip.Store, // Directories are just entries, no compression needed
+		Modified: z.stamp(modified),
 	}
+	header.SetMode(os.ModeDir | 0755)
 
 	_, err := z.writer.CreateHeader(header)
 	if err != nil {
@@ -87,3 +143,12 @@ func (z *ZipWriter) CreateDirEntry(dirPath string) error {
 
 	return nil
 }
+
+// stamp returns the modification time of an entry, the creation time of
+// the archive when it has none
+func (z *ZipWriter) stamp(modified time.Time) time.Time {
+	if modified.IsZero() {
+		return z.created
+	}
+	return modified
+}
@@ -0,0 +1,126 @@
+package forge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/liagha/gitdig/internal/config"
+	"github.com/liagha/gitdig/internal/display"
+)
+
+// Client sends the API requests of all hosting services
+var Client = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// maxRateLimitWaits is how often a request waits for a rate limit to reset
+// before giving up
+const maxRateLimitWaits = 3
+
+// Authorizer adds a hosting service's credentials to a request
+type Authorizer func(req *http.Request)
+
+// NewRequest builds a request carrying the user agent and, when auth is set,
+// the credentials
+func NewRequest(method, url string, auth Authorizer) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if auth != nil {
+		auth(req)
+	}
+
+	req.Header.Set("User-Agent", config.AppName+"/"+config.AppVersion)
+	return req, nil
+}
+
+// GetJSON performs a GET request, decodes the JSON response into v and
+// returns the URL of the next page of a paginated listing, or an empty string
+// on the last page. service names the hosting service in errors. When the
+// service reports a rate limit along with its reset time, the request is
+// repeated after waiting for it.
+func GetJSON(service, apiURL string, auth Authorizer, v interface{}) (string, error) {
+	for waits := 0; ; waits++ {
+		next, wait, err := getJSON(service, apiURL, auth, v)
+		if wait < 0 || waits == maxRateLimitWaits {
+			return next, err
+		}
+
+		display.Yellow("%s API rate limit exceeded. Reset in %s. Waiting...\n", service, wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+// getJSON performs a single attempt of GetJSON. A rate limited request
+// returns how long to wait before repeating it, any other outcome a negative
+// wait.
+func getJSON(service, apiURL string, auth Authorizer, v interface{}) (next string, wait time.Duration, err error) {
+	req, err := NewRequest("GET", apiURL, auth)
+	if err != nil {
+		return "", -1, err
+	}
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return "", -1, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close response body: %w", cerr)
+		}
+	}()
+
+	if rateLimited(resp) {
+		err := fmt.Errorf("%s API rate limit exceeded. Try authenticating with a token", service)
+		if wait, ok := rateLimitReset(resp.Header); ok {
+			return "", wait, err
+		}
+		return "", -1, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", -1, fmt.Errorf("%s API error: %s - %s", service, resp.Status, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", -1, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return NextLink(resp.Header.Get("Link")), -1, nil
+}
+
+// rateLimited reports whether resp turns a request down for exceeding a rate
+// limit. GitHub answers 403 rather than 429, telling the two apart from other
+// 403s by its headers.
+func rateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// rateLimitReset returns how long until a rate limit resets, from either a
+// Retry-After header or a reset time in Unix seconds
+func rateLimitReset(header http.Header) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+
+	for _, name := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if reset, err := strconv.ParseInt(header.Get(name), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), 0), true
+		}
+	}
+
+	return 0, false
+}
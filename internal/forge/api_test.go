@@ -0,0 +1,61 @@
+package forge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetJSON(t *testing.T) {
+	limited := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			if r.Header.Get("Authorization") != "token secret" {
+				t.Errorf("Authorization = %q, want the token", r.Header.Get("Authorization"))
+			}
+			w.Header().Set("Link", `<http://example.com/page?page=2>; rel="next", <http://example.com/page?page=9>; rel="last"`)
+			w.Write([]byte(`["a", "b"]`))
+		case "/limited":
+			if limited > 0 {
+				limited--
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(`["ok"]`))
+		case "/exhausted":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/forbidden":
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("no access"))
+		}
+	}))
+	defer server.Close()
+
+	auth := func(req *http.Request) { req.Header.Set("Authorization", "token secret") }
+
+	var items []string
+	next, err := GetJSON("Test", server.URL+"/page", auth, &items)
+	if err != nil {
+		t.Fatalf("GetJSON failed: %v", err)
+	}
+	if len(items) != 2 || next != "http://example.com/page?page=2" {
+		t.Errorf("GetJSON = %v, next %q", items, next)
+	}
+
+	items = nil
+	if _, err := GetJSON("Test", server.URL+"/limited", nil, &items); err != nil || len(items) != 1 {
+		t.Errorf("GetJSON after a rate limit = %v, %v, want the retried response", items, err)
+	}
+
+	if _, err := GetJSON("Test", server.URL+"/exhausted", nil, &items); err == nil || !strings.Contains(err.Error(), "Test API rate limit exceeded") {
+		t.Errorf("GetJSON without a reset time = %v, want a rate limit error", err)
+	}
+
+	if _, err := GetJSON("Test", server.URL+"/forbidden", nil, &items); err == nil || !strings.Contains(err.Error(), "Test API error: 403 Forbidden - no access") {
+		t.Errorf("GetJSON on a plain 403 = %v, want an API error", err)
+	}
+}
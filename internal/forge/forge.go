@@ -0,0 +1,130 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Supported hosting services
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+
+	ProviderBitbucket       = "bitbucket"
+	ProviderBitbucketServer = "bitbucket-server"
+)
+
+// Host holds the API base URL of a hosting service instance, and for GitHub
+// the base URL raw files are served from
+type Host struct {
+	APIURL string
+	RawURL string
+}
+
+// DownloadTarget describes what to download and where to save it. When the
+// ref and the path cannot be told apart from the URL alone, RefPath holds both
+// and Branch and DirPath are a first guess until the ref has been resolved.
+type DownloadTarget struct {
+	Provider string
+	Host     Host
+	Owner    string
+	Repo     string
+	Branch   string
+	DirPath  string
+	RefPath  string
+	Commit   string
+	LocalDir string
+	// OutputDir is the directory given with -o, where a target that turns
+	// out to be a single file is saved. Empty means the current directory.
+	OutputDir string
+	// Constraint is a semver range selecting the newest matching tag
+	Constraint string
+	// Source is the repository reference the target was parsed from
+	Source string
+}
+
+type Content struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Type        string `json:"type"`
+	DownloadURL string `json:"download_url"`
+	Size        int64  `json:"size"`
+	SHA         string `json:"sha"`
+	// SubmoduleURL is the repository URL of a submodule, when the listing
+	// provides it. The SHA of a submodule is its pinned commit.
+	SubmoduleURL string `json:"submodule_git_url"`
+	// Target is the target of a symlink, when the listing provides it
+	Target string `json:"target"`
+	// Mode is the git file mode, e.g. 100644, 100755 or 120000, when the
+	// listing provides it
+	Mode string `json:"mode"`
+}
+
+type Repository struct {
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	CloneURL    string `json:"clone_url"`
+	HTMLURL     string `json:"html_url"`
+}
+
+// TargetForms lists the accepted target forms for error messages
+const TargetForms = "expected owner/repo[/path], owner/repo/tree/REF[/path], " +
+	"a repository URL such as https://github.com/owner/repo/tree/REF/path, " +
+	"https://raw.githubusercontent.com/owner/repo/REF/path, " +
+	"git@host:owner/repo.git or git://host/owner/repo.git"
+
+// DecodeContents decodes a GitHub style Contents API response, which is an
+// array for directories and a single object for anything else
+func DecodeContents(raw json.RawMessage, contents *[]Content) error {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var content Content
+		if err := json.Unmarshal(trimmed, &content); err != nil {
+			return err
+		}
+		*contents = []Content{content}
+		return nil
+	}
+
+	return json.Unmarshal(raw, contents)
+}
+
+// StatContents picks the description of filePath out of a GitHub style
+// Contents API response for it
+func StatContents(contents []Content, filePath string) Content {
+	filePath = strings.Trim(filePath, "/")
+	if len(contents) == 1 && contents[0].Path == filePath && contents[0].Type != "dir" {
+		return contents[0]
+	}
+
+	return Content{Name: path.Base(filePath), Path: filePath, Type: "dir"}
+}
+
+// EscapePath escapes each segment of a slash separated repository path
+func EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// NextLink extracts the rel="next" URL from a Link header
+func NextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		segments := strings.Split(link, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(segments[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,251 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// CodebergAPIURL is the API base URL of codeberg.org
+const CodebergAPIURL = "https://codeberg.org/api/v1"
+
+// pageSize is the number of items requested per page of a paginated listing
+const pageSize = 50
+
+// Client talks to the API of a Gitea or Forgejo instance
+type Client struct {
+	APIURL string
+	Token  string
+}
+
+// NewClient creates a client for the Gitea API at apiURL
+func NewClient(apiURL, token string) *Client {
+	if apiURL == "" {
+		apiURL = CodebergAPIURL
+	}
+	return &Client{APIURL: strings.TrimSuffix(apiURL, "/"), Token: token}
+}
+
+// Name returns the name of the hosting service
+func (c *Client) Name() string {
+	return "Gitea"
+}
+
+// ListDirectory lists the direct children of dirPath at ref
+func (c *Client) ListDirectory(owner, repo, ref, dirPath string) ([]forge.Content, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/contents/%s?ref=%s", c.APIURL, owner, repo, forge.EscapePath(strings.Trim(dirPath, "/")), url.QueryEscape(ref))
+
+	var raw json.RawMessage
+	if _, err := forge.GetJSON("Gitea", apiURL, c.authorize, &raw); err != nil {
+		return nil, err
+	}
+
+	var contents []forge.Content
+	if err := forge.DecodeContents(raw, &contents); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for i, content := range contents {
+		if content.DownloadURL == "" && (content.Type == "file" || content.Type == "symlink") {
+			contents[i].DownloadURL = c.rawURL(owner, repo, ref, content.Path)
+		}
+	}
+
+	return contents, nil
+}
+
+// Stat describes the file or directory at filePath
+func (c *Client) Stat(owner, repo, ref, filePath string) (forge.Content, error) {
+	contents, err := c.ListDirectory(owner, repo, ref, filePath)
+	if err != nil {
+		return forge.Content{}, err
+	}
+
+	return forge.StatContents(contents, filePath), nil
+}
+
+// LFSEndpoint returns the Git LFS server of the repository, which takes the
+// token as the password of basic authentication
+func (c *Client) LFSEndpoint(owner, repo string) lfs.Endpoint {
+	webURL := strings.TrimSuffix(c.APIURL, "/api/v1")
+
+	endpoint := lfs.Endpoint{URL: fmt.Sprintf("%s/%s/%s.git/info/lfs", webURL, owner, repo)}
+	if c.Token != "" {
+		endpoint.Authorization = lfs.BasicAuth("oauth2", c.Token)
+	}
+	return endpoint
+}
+
+// OpenFile starts downloading the content of a listed file
+func (c *Client) OpenFile(content forge.Content, from resume.Point) (*resume.Response, error) {
+	req, err := forge.NewRequest("GET", content.DownloadURL, c.authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	return resume.Get(resume.Client, req, from)
+}
+
+// OpenArchive starts downloading a gzipped tarball of the repository at ref.
+// Gitea always archives the whole repository, below a single directory named
+// after it.
+func (c *Client) OpenArchive(owner, repo, ref, dirPath string) (io.ReadCloser, error) {
+	req, err := forge.NewRequest("GET", fmt.Sprintf("%s/repos/%s/%s/archive/%s.tar.gz", c.APIURL, owner, repo, url.PathEscape(ref)), c.authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := resume.Get(resume.Client, req, resume.Point{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ResolveRef resolves a branch, tag or commit to a full commit SHA
+func (c *Client) ResolveRef(owner, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits?sha=%s&limit=1&stat=false", c.APIURL, owner, repo, url.QueryEscape(ref))
+
+	var commits []struct {
+		SHA string `json:"sha"`
+	}
+	if _, err := forge.GetJSON("Gitea", apiURL, c.authorize, &commits); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("failed to resolve ref %s: no commits found", ref)
+	}
+
+	return commits[0].SHA, nil
+}
+
+// CommitTimes returns the time of the last commit at ref that changed each
+// of paths, asking for the history of one path at a time
+func (c *Client) CommitTimes(owner, repo, ref string, paths []string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	for _, p := range paths {
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/commits?sha=%s&limit=1&stat=false&files=false", c.APIURL, owner, repo, url.QueryEscape(ref))
+		if p != "" {
+			apiURL += "&path=" + url.QueryEscape(p)
+		}
+
+		var commits []struct {
+			Commit struct {
+				Committer struct {
+					Date time.Time `json:"date"`
+				} `json:"committer"`
+			} `json:"commit"`
+		}
+		if _, err := forge.GetJSON("Gitea", apiURL, c.authorize, &commits); err != nil {
+			return nil, fmt.Errorf("failed to get history of %s: %w", p, err)
+		}
+		if len(commits) > 0 {
+			times[p] = commits[0].Commit.Committer.Date
+		}
+	}
+
+	return times, nil
+}
+
+// DefaultBranch returns the name of the repository's default branch
+func (c *Client) DefaultBranch(owner, repo string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s", c.APIURL, owner, repo)
+
+	var repository struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := forge.GetJSON("Gitea", apiURL, c.authorize, &repository); err != nil {
+		return "", fmt.Errorf("failed to get repository metadata: %w", err)
+	}
+
+	return repository.DefaultBranch, nil
+}
+
+// ListRefs lists the names of all branches and tags. The Gitea API cannot
+// filter by prefix, so the caller is left to do so.
+func (c *Client) ListRefs(owner, repo, prefix string) ([]string, error) {
+	branches, err := c.listRefNames(owner, repo, "branches")
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.listRefNames(owner, repo, "tags")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(branches, tags...), nil
+}
+
+// ListTags lists the names of all tags
+func (c *Client) ListTags(owner, repo string) ([]string, error) {
+	return c.listRefNames(owner, repo, "tags")
+}
+
+// listRefNames lists the names of all branches or tags
+func (c *Client) listRefNames(owner, repo, kind string) ([]string, error) {
+	var names []string
+	for page := 1; ; page++ {
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/%s?page=%d&limit=%d", c.APIURL, owner, repo, kind, page, pageSize)
+
+		var refs []struct {
+			Name string `json:"name"`
+		}
+		if _, err := forge.GetJSON("Gitea", apiURL, c.authorize, &refs); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+
+		for _, ref := range refs {
+			names = append(names, ref.Name)
+		}
+		if len(refs) < pageSize {
+			return names, nil
+		}
+	}
+}
+
+// ListRepositories lists the repositories of an organization, falling back
+// to the repositories of a user with that name
+func (c *Client) ListRepositories(owner string) ([]forge.Repository, error) {
+	repos, err := c.listRepositories(fmt.Sprintf("%s/orgs/%s/repos", c.APIURL, url.PathEscape(owner)))
+	if err == nil {
+		return repos, nil
+	}
+
+	return c.listRepositories(fmt.Sprintf("%s/users/%s/repos", c.APIURL, url.PathEscape(owner)))
+}
+
+func (c *Client) listRepositories(apiURL string) ([]forge.Repository, error) {
+	var repos []forge.Repository
+	for page := 1; ; page++ {
+		var batch []forge.Repository
+		if _, err := forge.GetJSON("Gitea", fmt.Sprintf("%s?page=%d&limit=%d", apiURL, page, pageSize), c.authorize, &batch); err != nil {
+			return nil, err
+		}
+
+		repos = append(repos, batch...)
+		if len(batch) < pageSize {
+			return repos, nil
+		}
+	}
+}
+
+// rawURL returns the API URL serving the raw content of a file
+func (c *Client) rawURL(owner, repo, ref, filePath string) string {
+	return fmt.Sprintf("%s/repos/%s/%s/raw/%s?ref=%s", c.APIURL, owner, repo, forge.EscapePath(filePath), url.QueryEscape(ref))
+}
+
+// authorize sends the token in the Authorization header
+func (c *Client) authorize(req *http.Request) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+}
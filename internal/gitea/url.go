@@ -0,0 +1,46 @@
+package gitea
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// IsURL reports whether u points at a Gitea or Forgejo instance, either
+// codeberg.org or a self-hosted instance recognised by its /src/ routes
+func IsURL(u *url.URL) bool {
+	if u.Host == "codeberg.org" {
+		return true
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return len(parts) >= 4 && parts[2] == "src" && (parts[3] == "branch" || parts[3] == "tag" || parts[3] == "commit")
+}
+
+// ParseURL parses https://host/owner/repo/src/branch/ref/path URLs
+func ParseURL(u *url.URL) (target forge.DownloadTarget, err error) {
+	target.Provider = forge.ProviderGitea
+	target.Host = forge.Host{APIURL: u.Scheme + "://" + u.Host + "/api/v1"}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return forge.DownloadTarget{}, errors.New("invalid Gitea URL format, must be at least owner/repo")
+	}
+
+	target.Owner = parts[0]
+	target.Repo = strings.TrimSuffix(parts[1], ".git")
+
+	if len(parts) >= 5 && parts[2] == "src" {
+		target.Branch = parts[4]
+		if parts[3] != "commit" {
+			target.RefPath = strings.Join(parts[4:], "/")
+		}
+		if len(parts) > 5 {
+			target.DirPath = strings.Join(parts[5:], "/")
+		}
+	}
+
+	return target, nil
+}
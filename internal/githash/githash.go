@@ -0,0 +1,68 @@
+package githash
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+)
+
+// NewBlob returns a hash computing the object ID of a git blob of size
+// bytes, which is the hash of "blob <size>\x00" followed by the content. The
+// object format is taken from like, an existing object ID: SHA-256 for 64
+// hex digits, SHA-1 otherwise.
+func NewBlob(size int64, like string) hash.Hash {
+	var h hash.Hash
+	if len(like) == 2*sha256.Size {
+		h = sha256.New()
+	} else {
+		h = sha1.New()
+	}
+
+	h.Write([]byte("blob " + strconv.FormatInt(size, 10) + "\x00"))
+	return h
+}
+
+// IsObjectID reports whether id is a full SHA-1 or SHA-256 object ID
+func IsObjectID(id string) bool {
+	if len(id) != 2*sha1.Size && len(id) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// Sum returns the hex-encoded sum of h
+func Sum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashFile returns the blob ID of the file at filePath, in the object format
+// of like
+func HashFile(filePath, like string) (id string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close file: %w", cerr)
+		}
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	h := NewBlob(stat.Size(), like)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	return Sum(h), nil
+}
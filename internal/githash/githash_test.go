@@ -0,0 +1,62 @@
+package githash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewBlob(t *testing.T) {
+	content := "hello\n"
+	tests := []struct {
+		like string
+		want string
+	}{
+		{like: strings.Repeat("0", 40), want: "ce013625030ba8dba906f756967f9e9ca394464a"},
+		{like: strings.Repeat("0", 64), want: "2cf8d83d9ee29543b34a87727421fdecb7e3f3a183d337639025de576db9ebb4"},
+	}
+
+	for _, tt := range tests {
+		h := NewBlob(int64(len(content)), tt.like)
+		h.Write([]byte(content))
+		if got := Sum(h); got != tt.want {
+			t.Errorf("blob ID in the format of a %d digit ID = %s, want %s", len(tt.like), got, tt.want)
+		}
+	}
+}
+
+func TestIsObjectID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "ce013625030ba8dba906f756967f9e9ca394464a", want: true},
+		{id: "2cf8d83d9ee29543b34a87727421fdecb7e3f3a183d337639025de576db9ebb4", want: true},
+		{id: "ce01362", want: false},
+		{id: "", want: false},
+		{id: strings.Repeat("g", 40), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsObjectID(tt.id); got != tt.want {
+			t.Errorf("IsObjectID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "hello")
+	if err := os.WriteFile(filePath, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := HashFile(filePath, "ce013625030ba8dba906f756967f9e9ca394464a")
+	if err != nil || id != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("HashFile = %s, %v, want the blob ID of the file", id, err)
+	}
+
+	if _, err := HashFile(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("HashFile of a missing file succeeded")
+	}
+}
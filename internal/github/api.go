@@ -4,170 +4,234 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
-	"time"
 
-	"github.com/liagha/gitdig/internal/config"
-	"github.com/liagha/gitdig/internal/display"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/resume"
 )
 
-type Content struct {
-	Name        string `json:"name"`
-	Path        string `json:"path"`
-	Type        string `json:"type"`
-	DownloadURL string `json:"download_url"`
-	Size        int64  `json:"size"`
-	SHA         string `json:"sha"`
+// PublicHost is the host used for github.com
+var PublicHost = forge.Host{
+	APIURL: "https://api.github.com",
+	RawURL: "https://raw.githubusercontent.com",
 }
 
-var client = &http.Client{
-	Timeout: 30 * time.Second,
-}
-
-func createRequest(method, url, token string) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// HostFor returns the endpoints of the GitHub instance served at hostname.
+// GitHub Enterprise Server exposes its API under /api/v3 and raw files under /raw.
+func HostFor(hostname string) forge.Host {
+	if hostname == "github.com" || hostname == "www.github.com" || hostname == "api.github.com" {
+		return PublicHost
 	}
 
-	if token != "" {
-		req.Header.Set("Authorization", "token "+token)
+	return forge.Host{
+		APIURL: "https://" + hostname + "/api/v3",
+		RawURL: "https://" + hostname + "/raw",
 	}
+}
 
-	req.Header.Set("User-Agent", config.AppName+"/"+config.AppVersion)
-	return req, nil
+// Client talks to the API of a single GitHub instance
+type Client struct {
+	Host  forge.Host
+	Token string
 }
 
-func GetContents(apiURL, token string) (contents []Content, err error) {
-	req, err := createRequest("GET", apiURL, token)
-	if err != nil {
-		return nil, err
+// NewClient creates a client for the given host
+func NewClient(host forge.Host, token string) *Client {
+	host.APIURL = strings.TrimSuffix(host.APIURL, "/")
+	host.RawURL = strings.TrimSuffix(host.RawURL, "/")
+	if host.RawURL == "" {
+		host.RawURL = PublicHost.RawURL
 	}
+	return &Client{Host: host, Token: token}
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil && err == nil {
-			err = fmt.Errorf("failed to close response body: %w", cerr)
+// authorizer returns the Authorizer sending token, if any
+func authorizer(token string) forge.Authorizer {
+	return func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "token "+token)
 		}
-	}()
-
-	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
-		rateLimitReset := resp.Header.Get("X-RateLimit-Reset")
-		if rateLimitReset != "" {
-			resetInt, parseErr := time.ParseDuration(rateLimitReset + "s")
-			if parseErr == nil {
-				waitTime := resetInt - time.Duration(time.Now().Unix())
-				display.Yellow("Rate limit exceeded. Reset in %.0f minutes. Waiting...\n", waitTime.Minutes())
-				time.Sleep(waitTime)
-				return GetContents(apiURL, token)
-			}
-		}
-		return nil, errors.New("GitHub API rate limit exceeded. Try using authentication with --token")
 	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s - %s", resp.Status, string(body))
+// GetContents lists a directory through the Contents API. When dirPath is a
+// file, the API answers with a single object, which is returned on its own.
+func (c *Client) GetContents(owner, repo, dirPath, ref string) (contents []forge.Content, err error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/contents/%s?ref=%s", c.Host.APIURL, owner, repo, forge.EscapePath(dirPath), url.QueryEscape(ref))
+
+	var raw json.RawMessage
+	if _, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &raw); err != nil {
+		return nil, err
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&contents); err != nil {
+	if err := forge.DecodeContents(raw, &contents); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	// Directory listings report submodules as files without a download URL
+	for i := range contents {
+		if contents[i].Type == "file" && contents[i].DownloadURL == "" {
+			contents[i].Type = "submodule"
+		}
+	}
+
 	return contents, nil
 }
 
-func ParsePath(path string) (owner, repo, branch, dirPath string, err error) {
-	branch = "master"
+// namePattern matches the owner and repository names GitHub allows
+var namePattern = regexp.MustCompile(`^[\w.-]+$`)
 
-	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
-		return parseURL(path)
+// splitRepoPath splits a URL or shorthand path into segments and checks that
+// it starts with a usable owner/repo pair. The repository name loses any
+// .git suffix.
+func splitRepoPath(p string) ([]string, error) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if parts[0] == "" {
+		return nil, fmt.Errorf("missing owner and repository name, %s", forge.TargetForms)
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return nil, fmt.Errorf("missing repository name after %q, expected %s/REPO", parts[0], parts[0])
 	}
 
-	parts := strings.Split(strings.Trim(path, "/"), "/")
+	parts[1] = strings.TrimSuffix(parts[1], ".git")
+	for _, part := range parts[:2] {
+		if part == "." || part == ".." || !namePattern.MatchString(part) {
+			return nil, fmt.Errorf("invalid owner or repository name %q, %s", part, forge.TargetForms)
+		}
+	}
 
-	if len(parts) < 2 {
-		return "", "", "", "", errors.New("invalid GitHub path format, must be at least owner/repo")
+	return parts, nil
+}
+
+// fileExtensions are extensions of files that are rarely part of a repository
+// name, so that a local file path such as docs/logo.png is not taken for an
+// owner/repo pair
+var fileExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".pdf": true, ".md": true, ".txt": true, ".csv": true, ".json": true, ".yaml": true, ".yml": true,
+	".zip": true, ".tar": true, ".gz": true, ".tgz": true,
+}
+
+// ParseShorthand parses an owner/repo[/path] or owner/repo/tree/REF[/path]
+// shorthand path into a download target on defaultHost
+func ParseShorthand(path string, defaultHost forge.Host) (target forge.DownloadTarget, err error) {
+	target.Provider = forge.ProviderGitHub
+	target.Host = defaultHost
+
+	parts, err := splitRepoPath(path)
+	if err != nil {
+		return forge.DownloadTarget{}, err
+	}
+	if i := strings.LastIndex(parts[1], "."); len(parts) == 2 && i > 0 && fileExtensions[strings.ToLower(parts[1][i:])] {
+		return forge.DownloadTarget{}, fmt.Errorf("%q looks like a file path rather than owner/repo, %s", path, forge.TargetForms)
 	}
 
-	owner = parts[0]
-	repo = parts[1]
+	target.Owner = parts[0]
+	target.Repo = parts[1]
 
-	if len(parts) >= 4 && parts[2] == "tree" {
-		branch = parts[3]
+	if len(parts) >= 4 && (parts[2] == "tree" || parts[2] == "blob") {
+		target.RefPath = strings.Join(parts[3:], "/")
+		target.Branch = parts[3]
 		if len(parts) > 4 {
-			dirPath = strings.Join(parts[4:], "/")
+			target.DirPath = strings.Join(parts[4:], "/")
 		}
 	} else if len(parts) > 2 {
-		dirPath = strings.Join(parts[2:], "/")
+		target.DirPath = strings.Join(parts[2:], "/")
 	}
 
-	return owner, repo, branch, dirPath, nil
+	return target, nil
 }
 
-func parseURL(rawURL string) (owner, repo, branch, path string, err error) {
-	branch = "master"
+// ParseURL parses a github.com, raw.githubusercontent.com or GitHub
+// Enterprise URL into a download target
+func ParseURL(parsedURL *url.URL, defaultHost forge.Host) (target forge.DownloadTarget, err error) {
+	if parsedURL.Host == "raw.githubusercontent.com" {
+		return parseRawURL(parsedURL, defaultHost)
+	}
 
-	parsedURL, err := url.Parse(rawURL)
-	if err != nil {
-		return "", "", "", "", fmt.Errorf("invalid URL: %w", err)
+	target.Provider = forge.ProviderGitHub
+
+	// A configured Enterprise host keeps its configured endpoints, any other
+	// host is assumed to follow the GitHub Enterprise Server layout
+	target.Host = HostFor(parsedURL.Host)
+	if configured, err := url.Parse(defaultHost.APIURL); err == nil && configured.Host == parsedURL.Host {
+		target.Host = defaultHost
 	}
 
-	if parsedURL.Host != "github.com" {
-		return "", "", "", "", errors.New("not a GitHub URL")
+	parts, err := splitRepoPath(parsedURL.Path)
+	if err != nil {
+		return forge.DownloadTarget{}, err
 	}
 
-	parts := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	target.Owner = parts[0]
+	target.Repo = parts[1]
 
-	if len(parts) < 2 {
-		return "", "", "", "", errors.New("invalid GitHub URL format")
+	if len(parts) == 2 {
+		return target, nil
 	}
 
-	owner = parts[0]
-	repo = parts[1]
-
-	if len(parts) >= 4 {
-		if parts[2] == "tree" || parts[2] == "blob" {
-			branch = parts[3]
-			if len(parts) > 4 {
-				path = strings.Join(parts[4:], "/")
-			}
+	switch parts[2] {
+	case "tree", "blob", "raw":
+		if len(parts) < 4 || parts[3] == "" {
+			return forge.DownloadTarget{}, fmt.Errorf("missing ref after /%s/, expected https://%s/owner/repo/%s/REF[/path]", parts[2], parsedURL.Host, parts[2])
+		}
+		target.RefPath = strings.Join(parts[3:], "/")
+		target.Branch = parts[3]
+		if len(parts) > 4 {
+			target.DirPath = strings.Join(parts[4:], "/")
 		}
+	default:
+		return forge.DownloadTarget{}, fmt.Errorf("unsupported GitHub URL path /%s, expected https://%s/owner/repo[/tree/REF/path] or /blob/REF/path", strings.Join(parts[2:], "/"), parsedURL.Host)
 	}
 
-	return owner, repo, branch, path, nil
+	return target, nil
 }
 
-func DownloadFileContent(url, token string) (data []byte, err error) {
-	req, err := createRequest("GET", url, token)
-	if err != nil {
-		return nil, err
+// parseRawURL parses https://raw.githubusercontent.com/owner/repo/REF/path
+// URLs, including the refs/heads/REF and refs/tags/REF forms
+func parseRawURL(u *url.URL, defaultHost forge.Host) (target forge.DownloadTarget, err error) {
+	target.Provider = forge.ProviderGitHub
+	target.Host = PublicHost
+	if defaultHost.APIURL == PublicHost.APIURL {
+		target.Host = defaultHost
 	}
 
-	resp, err := client.Do(req)
+	parts, err := splitRepoPath(u.Path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute download request: %w", err)
+		return forge.DownloadTarget{}, err
+	}
+
+	target.Owner = parts[0]
+	target.Repo = parts[1]
+
+	refPath := parts[2:]
+	if len(refPath) >= 3 && refPath[0] == "refs" && (refPath[1] == "heads" || refPath[1] == "tags") {
+		refPath = refPath[2:]
+	}
+	if len(refPath) == 0 || refPath[0] == "" {
+		return forge.DownloadTarget{}, errors.New("missing ref in raw URL, expected https://raw.githubusercontent.com/owner/repo/REF/path")
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil && err == nil {
-			err = fmt.Errorf("failed to close response body: %w", cerr)
-		}
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	target.RefPath = strings.Join(refPath, "/")
+	target.Branch = refPath[0]
+	if len(refPath) > 1 {
+		target.DirPath = strings.Join(refPath[1:], "/")
 	}
 
-	data, err = io.ReadAll(resp.Body)
+	return target, nil
+}
+
+// OpenFileContent starts downloading a raw file, resuming an earlier
+// download when from is set. The caller reads the content from the returned
+// body and closes it.
+func (c *Client) OpenFileContent(url string, from resume.Point) (*resume.Response, error) {
+	req, err := forge.NewRequest("GET", url, authorizer(c.Token))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
-	return data, nil
+	return resume.Get(resume.Client, req, from)
 }
@@ -0,0 +1,123 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// graphQLBatchSize is the number of paths looked up by a single GraphQL query
+const graphQLBatchSize = 50
+
+// graphQLURL returns the GraphQL endpoint of the host, which lives next to
+// the REST API: api.github.com/graphql, or /api/graphql on GitHub Enterprise
+func (c *Client) graphQLURL() string {
+	if base, ok := strings.CutSuffix(c.Host.APIURL, "/api/v3"); ok {
+		return base + "/api/graphql"
+	}
+	return c.Host.APIURL + "/graphql"
+}
+
+// graphQLCommitTimes looks up the last commit times of paths with one
+// GraphQL query per batch, asking for the history of each path through an
+// aliased field
+func (c *Client) graphQLCommitTimes(owner, repo, ref string, paths []string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+
+	for start := 0; start < len(paths); start += graphQLBatchSize {
+		batch := paths[start:min(start+graphQLBatchSize, len(paths))]
+
+		var fields strings.Builder
+		for i, p := range batch {
+			args := "first: 1"
+			if p != "" {
+				quoted, err := json.Marshal(p)
+				if err != nil {
+					return nil, err
+				}
+				args += ", path: " + string(quoted)
+			}
+			fmt.Fprintf(&fields, " p%d: history(%s) { nodes { committedDate } }", i, args)
+		}
+
+		query := fmt.Sprintf("query($owner: String!, $repo: String!, $ref: String!) { repository(owner: $owner, name: $repo) { object(expression: $ref) { ... on Commit {%s } } } }", fields.String())
+		variables := map[string]string{"owner": owner, "repo": repo, "ref": ref}
+
+		var result struct {
+			Repository struct {
+				Object map[string]struct {
+					Nodes []struct {
+						CommittedDate time.Time `json:"committedDate"`
+					} `json:"nodes"`
+				} `json:"object"`
+			} `json:"repository"`
+		}
+		if err := c.graphQL(query, variables, &result); err != nil {
+			return nil, err
+		}
+
+		for i, p := range batch {
+			history := result.Repository.Object[fmt.Sprintf("p%d", i)]
+			if len(history.Nodes) > 0 {
+				times[p] = history.Nodes[0].CommittedDate
+			}
+		}
+	}
+
+	return times, nil
+}
+
+// graphQL runs a GraphQL query and decodes its data into v
+func (c *Client) graphQL(query string, variables map[string]string, v interface{}) (err error) {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	req, err := forge.NewRequest("POST", c.graphQLURL(), authorizer(c.Token))
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+	req.ContentLength = int64(len(payload))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := forge.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close response body: %w", cerr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub GraphQL error: %s - %s", resp.Status, string(body))
+	}
+
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("GitHub GraphQL error: %s", response.Errors[0].Message)
+	}
+
+	if err := json.Unmarshal(response.Data, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package github
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// Name returns the name of the hosting service
+func (c *Client) Name() string {
+	return "GitHub"
+}
+
+// ListDirectory lists the direct children of dirPath at ref
+func (c *Client) ListDirectory(owner, repo, ref, dirPath string) ([]forge.Content, error) {
+	return c.GetContents(owner, repo, dirPath, ref)
+}
+
+// Stat describes the file or directory at filePath. Directories are returned
+// with type "dir" and no further details.
+func (c *Client) Stat(owner, repo, ref, filePath string) (forge.Content, error) {
+	contents, err := c.GetContents(owner, repo, filePath, ref)
+	if err != nil {
+		return forge.Content{}, err
+	}
+
+	return forge.StatContents(contents, filePath), nil
+}
+
+// LFSEndpoint returns the Git LFS server of the repository. GitHub accepts
+// the token as the password of basic authentication.
+func (c *Client) LFSEndpoint(owner, repo string) lfs.Endpoint {
+	webURL := strings.TrimSuffix(c.Host.APIURL, "/api/v3")
+	if c.Host.APIURL == PublicHost.APIURL {
+		webURL = "https://github.com"
+	}
+
+	endpoint := lfs.Endpoint{URL: fmt.Sprintf("%s/%s/%s.git/info/lfs", webURL, owner, repo)}
+	if c.Token != "" {
+		endpoint.Authorization = lfs.BasicAuth("x-access-token", c.Token)
+	}
+	return endpoint
+}
+
+// OpenFile starts downloading the content of a listed file
+func (c *Client) OpenFile(content forge.Content, from resume.Point) (*resume.Response, error) {
+	return c.OpenFileContent(content.DownloadURL, from)
+}
+
+// OpenArchive starts downloading a gzipped tarball of the repository at ref.
+// GitHub always archives the whole repository, below a single directory
+// named owner-repo-sha.
+func (c *Client) OpenArchive(owner, repo, ref, dirPath string) (io.ReadCloser, error) {
+	req, err := forge.NewRequest("GET", fmt.Sprintf("%s/repos/%s/%s/tarball/%s", c.Host.APIURL, owner, repo, forge.EscapePath(ref)), authorizer(c.Token))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := resume.Get(resume.Client, req, resume.Point{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ResolveRef resolves a branch, tag or commit to a full commit SHA
+func (c *Client) ResolveRef(owner, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits/%s", c.Host.APIURL, owner, repo, forge.EscapePath(ref))
+
+	var commit commitResponse
+	if _, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+
+	return commit.SHA, nil
+}
+
+// CommitTimes returns the time of the last commit at ref that changed each
+// of paths. With a token the paths are looked up in batches through the
+// GraphQL API, which cannot be used anonymously.
+func (c *Client) CommitTimes(owner, repo, ref string, paths []string) (map[string]time.Time, error) {
+	if c.Token != "" {
+		return c.graphQLCommitTimes(owner, repo, ref, paths)
+	}
+
+	times := make(map[string]time.Time)
+	for _, p := range paths {
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/commits?sha=%s&path=%s&per_page=1", c.Host.APIURL, owner, repo, url.QueryEscape(ref), url.QueryEscape(p))
+
+		var commits []commitResponse
+		if _, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &commits); err != nil {
+			return nil, fmt.Errorf("failed to get history of %s: %w", p, err)
+		}
+		if len(commits) > 0 {
+			times[p] = commits[0].Commit.Committer.Date
+		}
+	}
+
+	return times, nil
+}
+
+// DefaultBranch returns the name of the repository's default branch
+func (c *Client) DefaultBranch(owner, repo string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s", c.Host.APIURL, owner, repo)
+
+	var repository struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &repository); err != nil {
+		return "", fmt.Errorf("failed to get repository metadata: %w", err)
+	}
+
+	return repository.DefaultBranch, nil
+}
+
+// ListRefs lists the names of branches and tags starting with prefix
+func (c *Client) ListRefs(owner, repo, prefix string) ([]string, error) {
+	var names []string
+	for _, namespace := range []string{"heads", "tags"} {
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/git/matching-refs/%s/%s?per_page=100", c.Host.APIURL, owner, repo, namespace, forge.EscapePath(prefix))
+
+		for apiURL != "" {
+			var refs []struct {
+				Ref string `json:"ref"`
+			}
+			next, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &refs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list refs: %w", err)
+			}
+
+			for _, ref := range refs {
+				names = append(names, strings.TrimPrefix(ref.Ref, "refs/"+namespace+"/"))
+			}
+			apiURL = next
+		}
+	}
+
+	return names, nil
+}
+
+// ListTags lists the names of all tags
+func (c *Client) ListTags(owner, repo string) ([]string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=100", c.Host.APIURL, owner, repo)
+
+	var names []string
+	for apiURL != "" {
+		var tags []struct {
+			Name string `json:"name"`
+		}
+		next, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		apiURL = next
+	}
+
+	return names, nil
+}
+
+// ListRepositories lists the repositories of an organization, falling back
+// to the repositories of a user with that name
+func (c *Client) ListRepositories(owner string) ([]forge.Repository, error) {
+	repos, err := c.GetRepositoriesForOrg(owner)
+	if err == nil {
+		return repos, nil
+	}
+
+	return c.GetRepositoriesForUser(owner)
+}
@@ -0,0 +1,193 @@
+package github
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// TreeEntry is a single entry of a git tree as returned by the Git Trees API
+type TreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+	Size int64  `json:"size"`
+}
+
+// Tree is a git tree listing. Truncated is set when GitHub could not return
+// every entry of a recursive listing in one response.
+type Tree struct {
+	SHA       string      `json:"sha"`
+	Tree      []TreeEntry `json:"tree"`
+	Truncated bool        `json:"truncated"`
+}
+
+type commitResponse struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+// ResolveTreeSHA resolves a branch, tag or commit to the SHA of its root tree
+func (c *Client) ResolveTreeSHA(owner, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/commits/%s", c.Host.APIURL, owner, repo, forge.EscapePath(ref))
+
+	var commit commitResponse
+	if _, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+
+	return commit.Commit.Tree.SHA, nil
+}
+
+// GetTree fetches the tree with the given SHA, optionally with all of its subtrees
+func (c *Client) GetTree(owner, repo, sha string, recursive bool) (*Tree, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/git/trees/%s", c.Host.APIURL, owner, repo, sha)
+	if recursive {
+		apiURL += "?recursive=1"
+	}
+
+	var tree Tree
+	if _, err := forge.GetJSON("GitHub", apiURL, authorizer(c.Token), &tree); err != nil {
+		return nil, err
+	}
+
+	return &tree, nil
+}
+
+// ListTree lists everything below dirPath at the given ref using the Git Trees
+// API. The whole repository is fetched in one request where possible; if GitHub
+// truncates the listing, the directory is walked subtree by subtree instead.
+func (c *Client) ListTree(owner, repo, ref, dirPath string, recursive bool) ([]forge.Content, error) {
+	rootSHA, err := c.ResolveTreeSHA(owner, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	dirPath = strings.Trim(dirPath, "/")
+
+	if recursive {
+		tree, err := c.GetTree(owner, repo, rootSHA, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if !tree.Truncated {
+			var contents []forge.Content
+			for _, entry := range tree.Tree {
+				if dirPath != "" && !strings.HasPrefix(entry.Path, dirPath+"/") {
+					continue
+				}
+				contents = append(contents, c.treeEntryContent(owner, repo, ref, entry, ""))
+			}
+			return contents, nil
+		}
+	}
+
+	// Walk down to the requested directory one level at a time
+	sha := rootSHA
+	if dirPath != "" {
+		for _, name := range strings.Split(dirPath, "/") {
+			tree, err := c.GetTree(owner, repo, sha, false)
+			if err != nil {
+				return nil, err
+			}
+
+			found := false
+			for _, entry := range tree.Tree {
+				if entry.Path == name && entry.Type == "tree" {
+					sha = entry.SHA
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("directory not found: %s", dirPath)
+			}
+		}
+	}
+
+	return c.listSubtree(owner, repo, ref, sha, dirPath, recursive)
+}
+
+// listSubtree lists the tree with the given SHA located at base, splitting the
+// request into one call per subtree whenever a recursive listing is truncated.
+func (c *Client) listSubtree(owner, repo, ref, sha, base string, recursive bool) ([]forge.Content, error) {
+	if recursive {
+		tree, err := c.GetTree(owner, repo, sha, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if !tree.Truncated {
+			var contents []forge.Content
+			for _, entry := range tree.Tree {
+				contents = append(contents, c.treeEntryContent(owner, repo, ref, entry, base))
+			}
+			return contents, nil
+		}
+	}
+
+	tree, err := c.GetTree(owner, repo, sha, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []forge.Content
+	for _, entry := range tree.Tree {
+		content := c.treeEntryContent(owner, repo, ref, entry, base)
+		contents = append(contents, content)
+
+		if entry.Type == "tree" && recursive {
+			children, err := c.listSubtree(owner, repo, ref, entry.SHA, content.Path, true)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, children...)
+		}
+	}
+
+	return contents, nil
+}
+
+// treeEntryContent converts a tree entry located below base into the Content
+// representation used by the Contents API
+func (c *Client) treeEntryContent(owner, repo, ref string, entry TreeEntry, base string) forge.Content {
+	fullPath := entry.Path
+	if base != "" {
+		fullPath = base + "/" + entry.Path
+	}
+
+	content := forge.Content{
+		Name: path.Base(fullPath),
+		Path: fullPath,
+		Size: entry.Size,
+		SHA:  entry.SHA,
+		Mode: entry.Mode,
+	}
+
+	switch entry.Type {
+	case "tree":
+		content.Type = "dir"
+	case "commit":
+		content.Type = "submodule"
+	default:
+		content.Type = "file"
+		if entry.Mode == "120000" {
+			content.Type = "symlink"
+		}
+		content.DownloadURL = fmt.Sprintf("%s/%s/%s/%s/%s", c.Host.RawURL, owner, repo, forge.EscapePath(ref), forge.EscapePath(fullPath))
+	}
+
+	return content
+}
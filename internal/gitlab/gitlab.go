@@ -0,0 +1,326 @@
+package gitlab
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// PublicAPIURL is the API base URL of gitlab.com
+const PublicAPIURL = "https://gitlab.com/api/v4"
+
+// Client talks to the REST API of a GitLab instance
+type Client struct {
+	APIURL string
+	Token  string
+}
+
+type treeEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+type project struct {
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Description       string `json:"description"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	WebURL            string `json:"web_url"`
+}
+
+// NewClient creates a client for the GitLab API at apiURL
+func NewClient(apiURL, token string) *Client {
+	if apiURL == "" {
+		apiURL = PublicAPIURL
+	}
+	return &Client{APIURL: strings.TrimSuffix(apiURL, "/"), Token: token}
+}
+
+// Name returns the name of the hosting service
+func (c *Client) Name() string {
+	return "GitLab"
+}
+
+// ListDirectory lists the direct children of dirPath at ref
+func (c *Client) ListDirectory(owner, repo, ref, dirPath string) ([]forge.Content, error) {
+	return c.ListTree(owner, repo, ref, dirPath, false)
+}
+
+// ListTree lists dirPath at ref through the repository tree API, following
+// pagination until every entry has been returned
+func (c *Client) ListTree(owner, repo, ref, dirPath string, recursive bool) ([]forge.Content, error) {
+	query := url.Values{}
+	query.Set("ref", ref)
+	query.Set("per_page", "100")
+	query.Set("pagination", "keyset")
+	if dirPath != "" {
+		query.Set("path", strings.Trim(dirPath, "/"))
+	}
+	if recursive {
+		query.Set("recursive", "true")
+	}
+
+	apiURL := fmt.Sprintf("%s/repository/tree?%s", c.projectURL(owner, repo), query.Encode())
+
+	var contents []forge.Content
+	for apiURL != "" {
+		var entries []treeEntry
+		next, err := forge.GetJSON("GitLab", apiURL, c.authorize, &entries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repository tree: %w", err)
+		}
+
+		for _, entry := range entries {
+			contents = append(contents, c.entryContent(owner, repo, ref, entry))
+		}
+		apiURL = next
+	}
+
+	return contents, nil
+}
+
+// Stat describes the file or directory at filePath by looking it up in the
+// listing of its parent directory
+func (c *Client) Stat(owner, repo, ref, filePath string) (forge.Content, error) {
+	filePath = strings.Trim(filePath, "/")
+
+	parent := path.Dir(filePath)
+	if parent == "." {
+		parent = ""
+	}
+
+	contents, err := c.ListTree(owner, repo, ref, parent, false)
+	if err != nil {
+		return forge.Content{}, err
+	}
+
+	for _, content := range contents {
+		if content.Path == filePath {
+			return content, nil
+		}
+	}
+
+	return forge.Content{}, fmt.Errorf("path not found: %s", filePath)
+}
+
+// LFSEndpoint returns the Git LFS server of the project, which takes the
+// token as the password of basic authentication
+func (c *Client) LFSEndpoint(owner, repo string) lfs.Endpoint {
+	webURL := strings.TrimSuffix(c.APIURL, "/api/v4")
+
+	endpoint := lfs.Endpoint{URL: fmt.Sprintf("%s/%s/%s.git/info/lfs", webURL, owner, repo)}
+	if c.Token != "" {
+		endpoint.Authorization = lfs.BasicAuth("oauth2", c.Token)
+	}
+	return endpoint
+}
+
+// OpenFile starts downloading the content of a listed file
+func (c *Client) OpenFile(content forge.Content, from resume.Point) (*resume.Response, error) {
+	req, err := forge.NewRequest("GET", content.DownloadURL, c.authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	return resume.Get(resume.Client, req, from)
+}
+
+// OpenArchive starts downloading a gzipped tarball of the project at ref,
+// limited to dirPath. Entries keep their full path below a single top-level
+// directory.
+func (c *Client) OpenArchive(owner, repo, ref, dirPath string) (io.ReadCloser, error) {
+	apiURL := fmt.Sprintf("%s/repository/archive.tar.gz?sha=%s", c.projectURL(owner, repo), url.QueryEscape(ref))
+	if dirPath = strings.Trim(dirPath, "/"); dirPath != "" {
+		apiURL += "&path=" + url.QueryEscape(dirPath)
+	}
+
+	req, err := forge.NewRequest("GET", apiURL, c.authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := resume.Get(resume.Client, req, resume.Point{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ResolveRef resolves a branch, tag or commit to a full commit SHA
+func (c *Client) ResolveRef(owner, repo, ref string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repository/commits/%s", c.projectURL(owner, repo), url.PathEscape(ref))
+
+	var commit struct {
+		ID string `json:"id"`
+	}
+	if _, err := forge.GetJSON("GitLab", apiURL, c.authorize, &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+
+	return commit.ID, nil
+}
+
+// CommitTimes returns the time of the last commit at ref that changed each
+// of paths, asking for the history of one path at a time
+func (c *Client) CommitTimes(owner, repo, ref string, paths []string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	for _, p := range paths {
+		apiURL := fmt.Sprintf("%s/repository/commits?ref_name=%s&per_page=1", c.projectURL(owner, repo), url.QueryEscape(ref))
+		if p != "" {
+			apiURL += "&path=" + url.QueryEscape(p)
+		}
+
+		var commits []struct {
+			CommittedDate time.Time `json:"committed_date"`
+		}
+		if _, err := forge.GetJSON("GitLab", apiURL, c.authorize, &commits); err != nil {
+			return nil, fmt.Errorf("failed to get history of %s: %w", p, err)
+		}
+		if len(commits) > 0 {
+			times[p] = commits[0].CommittedDate
+		}
+	}
+
+	return times, nil
+}
+
+// DefaultBranch returns the name of the project's default branch
+func (c *Client) DefaultBranch(owner, repo string) (string, error) {
+	var p struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if _, err := forge.GetJSON("GitLab", c.projectURL(owner, repo), c.authorize, &p); err != nil {
+		return "", fmt.Errorf("failed to get project metadata: %w", err)
+	}
+
+	return p.DefaultBranch, nil
+}
+
+// ListRefs lists the names of branches and tags starting with prefix
+func (c *Client) ListRefs(owner, repo, prefix string) ([]string, error) {
+	branches, err := c.listRefNames(owner, repo, "branches", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.listRefNames(owner, repo, "tags", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(branches, tags...), nil
+}
+
+// ListTags lists the names of all tags
+func (c *Client) ListTags(owner, repo string) ([]string, error) {
+	return c.listRefNames(owner, repo, "tags", "")
+}
+
+// listRefNames lists the names of the branches or tags starting with prefix
+func (c *Client) listRefNames(owner, repo, kind, prefix string) ([]string, error) {
+	apiURL := fmt.Sprintf("%s/repository/%s?per_page=100", c.projectURL(owner, repo), kind)
+	if prefix != "" {
+		apiURL += "&search=" + url.QueryEscape("^"+prefix)
+	}
+
+	var names []string
+	for apiURL != "" {
+		var refs []struct {
+			Name string `json:"name"`
+		}
+		next, err := forge.GetJSON("GitLab", apiURL, c.authorize, &refs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+
+		for _, ref := range refs {
+			names = append(names, ref.Name)
+		}
+		apiURL = next
+	}
+
+	return names, nil
+}
+
+// ListRepositories lists the projects of a group including its subgroups,
+// falling back to the projects of a user with that name
+func (c *Client) ListRepositories(owner string) ([]forge.Repository, error) {
+	repos, err := c.listProjects(fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true&per_page=100", c.APIURL, url.PathEscape(owner)))
+	if err == nil {
+		return repos, nil
+	}
+
+	return c.listProjects(fmt.Sprintf("%s/users/%s/projects?per_page=100", c.APIURL, url.PathEscape(owner)))
+}
+
+func (c *Client) listProjects(apiURL string) ([]forge.Repository, error) {
+	var repos []forge.Repository
+	for apiURL != "" {
+		var projects []project
+		next, err := forge.GetJSON("GitLab", apiURL, c.authorize, &projects)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range projects {
+			repos = append(repos, forge.Repository{
+				Name:        p.Name,
+				FullName:    p.PathWithNamespace,
+				Description: p.Description,
+				CloneURL:    p.HTTPURLToRepo,
+				HTMLURL:     p.WebURL,
+			})
+		}
+		apiURL = next
+	}
+
+	return repos, nil
+}
+
+// projectURL returns the API URL of a project, addressed by its URL-encoded full path
+func (c *Client) projectURL(owner, repo string) string {
+	return fmt.Sprintf("%s/projects/%s", c.APIURL, url.PathEscape(owner+"/"+repo))
+}
+
+// entryContent converts a repository tree entry to the Content representation
+func (c *Client) entryContent(owner, repo, ref string, entry treeEntry) forge.Content {
+	content := forge.Content{
+		Name: path.Base(entry.Path),
+		Path: entry.Path,
+		SHA:  entry.ID,
+		Mode: entry.Mode,
+	}
+
+	switch entry.Type {
+	case "tree":
+		content.Type = "dir"
+	case "commit":
+		content.Type = "submodule"
+	default:
+		content.Type = "file"
+		if entry.Mode == "120000" {
+			content.Type = "symlink"
+		}
+		content.DownloadURL = fmt.Sprintf("%s/repository/files/%s/raw?ref=%s", c.projectURL(owner, repo), url.PathEscape(entry.Path), url.QueryEscape(ref))
+	}
+
+	return content
+}
+
+// authorize sends the token in the PRIVATE-TOKEN header
+func (c *Client) authorize(req *http.Request) {
+	if c.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.Token)
+	}
+}
@@ -0,0 +1,44 @@
+package gitlab
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/forge"
+)
+
+// IsURL reports whether u points at a GitLab instance, either gitlab.com
+// or a self-hosted instance recognised by its /-/ route separator
+func IsURL(u *url.URL) bool {
+	return u.Host == "gitlab.com" || strings.HasPrefix(u.Host, "gitlab.") || strings.Contains(u.Path, "/-/")
+}
+
+// ParseURL parses https://host/group/sub/project/-/tree/ref/path URLs.
+// The owner is the full group path, which may contain slashes.
+func ParseURL(u *url.URL) (target forge.DownloadTarget, err error) {
+	target.Provider = forge.ProviderGitLab
+	target.Host = forge.Host{APIURL: u.Scheme + "://" + u.Host + "/api/v4"}
+
+	projectPath, rest, _ := strings.Cut(strings.Trim(u.Path, "/"), "/-/")
+	projectPath = strings.TrimSuffix(projectPath, ".git")
+
+	parts := strings.Split(projectPath, "/")
+	if len(parts) < 2 {
+		return forge.DownloadTarget{}, errors.New("invalid GitLab URL format, must be at least group/project")
+	}
+
+	target.Owner = strings.Join(parts[:len(parts)-1], "/")
+	target.Repo = parts[len(parts)-1]
+
+	restParts := strings.Split(rest, "/")
+	if len(restParts) >= 2 && (restParts[0] == "tree" || restParts[0] == "blob") {
+		target.RefPath = strings.Join(restParts[1:], "/")
+		target.Branch = restParts[1]
+		if len(restParts) > 2 {
+			target.DirPath = strings.Join(restParts[2:], "/")
+		}
+	}
+
+	return target, nil
+}
@@ -0,0 +1,136 @@
+package glob
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Match reports whether name, a slash-separated path, matches the doublestar
+// pattern. Within a segment, * matches any sequence of characters, ? a single
+// character and [...] a character class. A segment consisting of ** matches
+// zero or more whole segments, and {a,b} matches either alternative.
+func Match(pattern, name string) bool {
+	nameParts := strings.Split(name, "/")
+	for _, p := range expandBraces(pattern) {
+		if matchSegments(strings.Split(p, "/"), nameParts) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports whether pattern is well-formed
+func Validate(pattern string) error {
+	for _, p := range expandBraces(pattern) {
+		for _, segment := range strings.Split(p, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// expandBraces expands the first {a,b} group of pattern, recursively, into
+// the list of patterns it stands for. Unbalanced braces are kept literally.
+func expandBraces(pattern string) []string {
+	start := -1
+	depth := 0
+	last := 0
+	var alternatives []string
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+			if depth == 1 {
+				start, last = i, i+1
+			}
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[last:i])
+
+				var expanded []string
+				for _, alternative := range alternatives {
+					expanded = append(expanded, expandBraces(pattern[:start]+alternative+pattern[i+1:])...)
+				}
+				return expanded
+			}
+		}
+	}
+
+	return []string{pattern}
+}
+
+// Filter selects paths by include and exclude patterns. A path is allowed
+// when it matches no exclude pattern and, if any include patterns are given,
+// at least one of them.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Active reports whether the filter has any patterns
+func (f Filter) Active() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// Allows reports whether name passes the filter
+func (f Filter) Allows(name string) bool {
+	for _, pattern := range f.Exclude {
+		if Match(pattern, name) {
+			return false
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
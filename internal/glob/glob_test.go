@@ -0,0 +1,133 @@
+package glob
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"?.go", "a.go", true},
+		{"?.go", "ab.go", false},
+		{"[a-c].txt", "b.txt", true},
+		{"[a-c].txt", "d.txt", false},
+
+		{"**", "any/depth/file", true},
+		{"**/*.proto", "api.proto", true},
+		{"**/*.proto", "api/v1/service.proto", true},
+		{"**/*.proto", "api/v1/service.go", false},
+		{"docs/**", "docs", true},
+		{"docs/**", "docs/guide/intro.md", true},
+		{"docs/**", "src/docs/intro.md", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/**/b", "a/x/b", true},
+		{"a/**/b", "a/x/c", false},
+		{"**/testdata/**", "pkg/testdata/golden.txt", true},
+
+		{"*.{go,mod}", "go.mod", true},
+		{"*.{go,mod}", "main.go", true},
+		{"*.{go,mod}", "go.sum", false},
+		{"{cmd,internal}/**/*.go", "internal/glob/glob.go", true},
+		{"{cmd,internal}/**/*.go", "pkg/glob.go", false},
+		{"src/{a,b{1,2}}.c", "src/b2.c", true},
+		{"src/{a,b{1,2}}.c", "src/b3.c", false},
+		{"{unbalanced", "{unbalanced", true},
+		{`\{a,b}`, "{a,b}", true},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"plain", []string{"plain"}},
+		{"{a,b}", []string{"a", "b"}},
+		{"x{a,b}y{1,2}", []string{"xay1", "xay2", "xby1", "xby2"}},
+		{"{a,{b,c}}", []string{"a", "b", "c"}},
+		{"{,s}", []string{"", "s"}},
+		{"a}b", []string{"a}b"}},
+	}
+
+	for _, tt := range tests {
+		if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, pattern := range []string{"**/*.go", "{a,b}/[0-9]*", "docs/**"} {
+		if err := Validate(pattern); err != nil {
+			t.Errorf("Validate(%q) failed: %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"[", "a/[b", "{x,[}"} {
+		if err := Validate(pattern); err == nil {
+			t.Errorf("Validate(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		allows []string
+		blocks []string
+	}{
+		{
+			name:   "no patterns",
+			filter: Filter{},
+			allows: []string{"a.go", "docs/x.md"},
+		},
+		{
+			name:   "include only",
+			filter: Filter{Include: []string{"**/*.proto", "README.md"}},
+			allows: []string{"api/v1/a.proto", "README.md"},
+			blocks: []string{"api/v1/a.go", "docs/README.md"},
+		},
+		{
+			name:   "exclude only",
+			filter: Filter{Exclude: []string{"**/testdata/**"}},
+			allows: []string{"pkg/a.go"},
+			blocks: []string{"pkg/testdata/in.txt"},
+		},
+		{
+			name:   "exclude wins over include",
+			filter: Filter{Include: []string{"**/*.go"}, Exclude: []string{"**/*_test.go"}},
+			allows: []string{"pkg/a.go"},
+			blocks: []string{"pkg/a_test.go", "pkg/a.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got, want := tt.filter.Active(), len(tt.filter.Include)+len(tt.filter.Exclude) > 0; got != want {
+			t.Errorf("%s: Active() = %v, want %v", tt.name, got, want)
+		}
+		for _, name := range tt.allows {
+			if !tt.filter.Allows(name) {
+				t.Errorf("%s: Allows(%q) = false, want true", tt.name, name)
+			}
+		}
+		for _, name := range tt.blocks {
+			if tt.filter.Allows(name) {
+				t.Errorf("%s: Allows(%q) = true, want false", tt.name, name)
+			}
+		}
+	}
+}
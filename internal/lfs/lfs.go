@@ -0,0 +1,271 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/config"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// pointerHeader is the first line of every Git LFS pointer file
+const pointerHeader = "version https://git-lfs.github.com/spec/v1\n"
+
+// MaxPointerSize bounds the size of pointer files; anything larger is content
+const MaxPointerSize = 1024
+
+const mediaType = "application/vnd.git-lfs+json"
+
+// Pointer identifies an object stored in Git LFS
+type Pointer struct {
+	OID  string
+	Size int64
+}
+
+// Endpoint is the LFS server of a repository, e.g.
+// https://github.com/owner/repo.git/info/lfs, along with the value of the
+// Authorization header to send. An empty Authorization sends no credentials.
+type Endpoint struct {
+	URL           string
+	Authorization string
+}
+
+// BasicAuth returns the Authorization header value for basic authentication
+func BasicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// ParsePointer reports whether data is a Git LFS pointer file and returns the
+// object it points to
+func ParsePointer(data []byte) (Pointer, bool) {
+	var pointer Pointer
+
+	if len(data) > MaxPointerSize || !bytes.HasPrefix(data, []byte(pointerHeader)) {
+		return pointer, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			oid, ok := strings.CutPrefix(value, "sha256:")
+			if !ok || len(oid) != sha256.Size*2 {
+				return pointer, false
+			}
+			if _, err := hex.DecodeString(oid); err != nil {
+				return pointer, false
+			}
+			pointer.OID = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return pointer, false
+			}
+			pointer.Size = size
+		}
+	}
+
+	return pointer, pointer.OID != ""
+}
+
+// MatchesFile reports whether the file at filePath is the object p points
+// to, comparing its size and sha256
+func (p Pointer) MatchesFile(filePath string) (ok bool, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close file: %w", cerr)
+		}
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if stat.Size() != p.Size {
+		return false, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) == p.OID, nil
+}
+
+type batchRequest struct {
+	Operation string        `json:"operation"`
+	Transfers []string      `json:"transfers"`
+	Objects   []batchObject `json:"objects"`
+}
+
+type batchObject struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type batchResponse struct {
+	Objects []struct {
+		batchObject
+		Actions struct {
+			Download *struct {
+				Href   string            `json:"href"`
+				Header map[string]string `json:"header"`
+			} `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// Open resolves the object behind pointer through the LFS batch API and
+// returns a stream of its content. Reading the stream to the end fails if the
+// content does not match the pointer's size and sha256 oid.
+//
+// When from is set, the transfer resumes after the first from.Offset bytes
+// of the object, which prefix supplies so that the whole object is verified.
+// The response tells whether the server resumed it; if not, prefix is not
+// read.
+func Open(endpoint Endpoint, pointer Pointer, from resume.Point, prefix io.Reader) (body *resume.Response, err error) {
+	payload, err := json.Marshal(batchRequest{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   []batchObject{{OID: pointer.OID, Size: pointer.Size}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode LFS batch request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(endpoint.URL, "/")+"/objects/batch", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+	req.Header.Set("User-Agent", config.AppName+"/"+config.AppVersion)
+	if endpoint.Authorization != "" {
+		req.Header.Set("Authorization", endpoint.Authorization)
+	}
+
+	resp, err := resume.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute LFS batch request: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close response body: %w", cerr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("LFS batch API error: %s - %s", resp.Status, string(message))
+	}
+
+	var batch batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("failed to decode LFS batch response: %w", err)
+	}
+
+	for _, object := range batch.Objects {
+		if object.OID != pointer.OID {
+			continue
+		}
+		if object.Error != nil {
+			return nil, fmt.Errorf("LFS object %s: %s (%d)", pointer.OID, object.Error.Message, object.Error.Code)
+		}
+		if object.Actions.Download == nil {
+			return nil, fmt.Errorf("LFS object %s: no download action", pointer.OID)
+		}
+		return download(object.Actions.Download.Href, object.Actions.Download.Header, pointer, from, prefix)
+	}
+
+	return nil, fmt.Errorf("LFS object %s missing from batch response", pointer.OID)
+}
+
+// download starts the transfer of an object from the URL given by the batch
+// API, resuming it after prefix when from is set
+func download(href string, header map[string]string, pointer Pointer, from resume.Point, prefix io.Reader) (*resume.Response, error) {
+	req, err := http.NewRequest("GET", href, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", config.AppName+"/"+config.AppVersion)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := resume.Get(resume.Client, req, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download LFS object: %w", err)
+	}
+
+	verifier := &verifyingReader{body: resp.Body, hash: sha256.New(), pointer: pointer}
+	if resp.Offset > 0 {
+		n, err := io.CopyN(verifier.hash, prefix, resp.Offset)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to read partial LFS object: %w", err)
+		}
+		verifier.size = n
+	}
+	resp.Body = verifier
+
+	return resp, nil
+}
+
+// ErrMismatch is returned when a downloaded object does not match its pointer
+var ErrMismatch = errors.New("LFS object does not match its pointer")
+
+// verifyingReader hashes the object as it is read and checks it against the
+// pointer once the end is reached
+type verifyingReader struct {
+	body    io.ReadCloser
+	hash    hash.Hash
+	size    int64
+	pointer Pointer
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	r.size += int64(n)
+
+	if r.size > r.pointer.Size {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrMismatch, r.pointer.Size)
+	}
+
+	if err == io.EOF {
+		if r.size != r.pointer.Size {
+			return n, fmt.Errorf("%w: got %d bytes, expected %d", ErrMismatch, r.size, r.pointer.Size)
+		}
+		if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.pointer.OID {
+			return n, fmt.Errorf("%w: sha256 %s, expected %s", ErrMismatch, sum, r.pointer.OID)
+		}
+	}
+
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,157 @@
+package resume
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Client streams downloads. It waits at most 30 seconds for a response but
+// puts no limit on the transfer itself, so that large files are not cut off.
+// A transfer that stops making progress is cut off by the body Get returns.
+var Client = &http.Client{
+	Transport: func() http.RoundTripper {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = 30 * time.Second
+		return transport
+	}(),
+}
+
+// idleTimeout is how long the body of a download may go without receiving
+// any data before it fails with ErrStalled
+var idleTimeout = 60 * time.Second
+
+// ErrStalled is returned by the body of a download that received nothing
+// for too long. What was received so far can be resumed.
+var ErrStalled = errors.New("download stalled")
+
+// Point is where an interrupted download stopped: the number of bytes
+// received and the entity tag of the file they came from. The zero Point
+// starts at the beginning.
+type Point struct {
+	Offset int64
+	ETag   string
+}
+
+// Response is a download started by Get
+type Response struct {
+	Body io.ReadCloser
+	// ETag is the strong entity tag of the file. It is empty when the server
+	// sent none, or one that cannot be used to resume the download.
+	ETag string
+	// Offset is the position of Body in the file: the offset asked for when
+	// the server resumed the download, 0 when it sent the whole file
+	Offset int64
+}
+
+// Get sends req, a GET request for a file. When from is set, only the part
+// of the file after from.Offset is asked for, on the condition that the file
+// still has the entity tag from.ETag; if it changed, the server sends the
+// whole file instead. Responses other than the file are errors.
+func Get(client *http.Client, req *http.Request, from Point) (*Response, error) {
+	resuming := from.Offset > 0 && from.ETag != ""
+	if resuming {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(from.Offset, 10)+"-")
+		req.Header.Set("If-Range", from.ETag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute download request: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return &Response{Body: newIdleReader(resp.Body, idleTimeout), ETag: strongETag(resp)}, nil
+
+	case resp.StatusCode == http.StatusPartialContent && resuming:
+		start, ok := rangeStart(resp.Header.Get("Content-Range"))
+		if !ok || start != from.Offset {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		return &Response{Body: newIdleReader(resp.Body, idleTimeout), ETag: from.ETag, Offset: from.Offset}, nil
+
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && resuming:
+		// What was received is no shorter than the file, so it cannot be a
+		// prefix of it: start over
+		resp.Body.Close()
+		req = req.Clone(req.Context())
+		req.Header.Del("Range")
+		req.Header.Del("If-Range")
+		return Get(client, req, Point{})
+
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+}
+
+// strongETag returns the entity tag of a response if a download can be
+// resumed with it. Weak tags are not allowed in If-Range, and the tag of a
+// response the transport decompressed describes the compressed bytes.
+func strongETag(resp *http.Response) string {
+	etag := resp.Header.Get("ETag")
+	if resp.Uncompressed || !strings.HasPrefix(etag, `"`) {
+		return ""
+	}
+	return etag
+}
+
+// rangeStart returns the first byte position of a Content-Range header such
+// as "bytes 100-199/200"
+func rangeStart(contentRange string) (int64, bool) {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// idleReader closes a response body that receives nothing for longer than
+// timeout, which makes a read blocked on a stalled connection return
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func newIdleReader(body io.ReadCloser, timeout time.Duration) *idleReader {
+	r := &idleReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		r.stalled.Store(true)
+		r.body.Close()
+	})
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.stalled.Load() {
+		return n, fmt.Errorf("%w: nothing received for %s", ErrStalled, r.timeout)
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	return r.body.Close()
+}
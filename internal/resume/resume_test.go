@@ -0,0 +1,129 @@
+package resume
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const content = "0123456789"
+
+func TestGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/weak":
+			w.Header().Set("ETag", `W/"v1"`)
+			w.Write([]byte(content))
+		case "/bad-range":
+			w.Header().Set("Content-Range", "bytes 0-9/10")
+			w.WriteHeader(http.StatusPartialContent)
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		from       Point
+		wantBody   string
+		wantETag   string
+		wantOffset int64
+		wantErr    bool
+	}{
+		{path: "/file", wantBody: content, wantETag: `"v1"`},
+		{path: "/file", from: Point{Offset: 4, ETag: `"v1"`}, wantBody: content[4:], wantETag: `"v1"`, wantOffset: 4},
+		// A changed file is sent whole
+		{path: "/file", from: Point{Offset: 4, ETag: `"v2"`}, wantBody: content, wantETag: `"v1"`},
+		// More than the file holds cannot be a prefix of it
+		{path: "/file", from: Point{Offset: 20, ETag: `"v1"`}, wantBody: content, wantETag: `"v1"`},
+		{path: "/weak", wantBody: content},
+		{path: "/bad-range", from: Point{Offset: 4, ETag: `"v1"`}, wantErr: true},
+		{path: "/missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", server.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := Get(server.Client(), req, tt.from)
+		if tt.wantErr {
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("Get(%s, %+v) succeeded, want an error", tt.path, tt.from)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Get(%s, %+v) failed: %v", tt.path, tt.from, err)
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != tt.wantBody || resp.ETag != tt.wantETag || resp.Offset != tt.wantOffset {
+			t.Errorf("Get(%s, %+v) = %q (%v), ETag %q, offset %d, want %q, ETag %q, offset %d",
+				tt.path, tt.from, body, err, resp.ETag, resp.Offset, tt.wantBody, tt.wantETag, tt.wantOffset)
+		}
+	}
+}
+
+func TestGetStalled(t *testing.T) {
+	defer func(timeout time.Duration) { idleTimeout = timeout }(idleTimeout)
+	idleTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte(content[:4]))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Get(server.Client(), req, Point{})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, ErrStalled) || string(body) != content[:4] {
+		t.Errorf("reading a stalled body = %q, %v, want %q and ErrStalled", body, err, content[:4])
+	}
+}
+
+func TestRangeStart(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		ok     bool
+	}{
+		{header: "bytes 100-199/200", want: 100, ok: true},
+		{header: "bytes 0-0/*", want: 0, ok: true},
+		{header: "bytes */200", ok: false},
+		{header: "items 1-2/3", ok: false},
+		{header: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := rangeStart(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("rangeStart(%q) = %d, %v, want %d, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Build metadata is ignored.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+}
+
+// Parse parses a version such as "1.4.2", "v2.0.0-rc.1" or "1.4". Missing
+// minor and patch numbers default to zero.
+func Parse(s string) (Version, error) {
+	var v Version
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return v, fmt.Errorf("invalid version %q: empty pre-release", s)
+		}
+		v.Prerelease = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || parts[0] == "" {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// String formats the version without a "v" prefix
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to
+// or higher than other, following the semver precedence rules
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	// A version without pre-release has higher precedence than one with it
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		a, b := v.Prerelease[i], other.Prerelease[i]
+		aNum, aErr := strconv.Atoi(a)
+		bNum, bErr := strconv.Atoi(b)
+
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInt(aNum, bNum)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(a, b)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return compareInt(len(v.Prerelease), len(other.Prerelease))
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparator is a single bound such as ">=1.4.0"
+type comparator struct {
+	op      string
+	version Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// Constraint is a set of version ranges, any of which may match
+type Constraint struct {
+	ranges [][]comparator
+}
+
+// ParseConstraint parses constraints such as "^1.4", "~2.3.0", ">=1.2 <2",
+// "1.x" or "^1.0 || ^2.0". Comparators within a range are separated by spaces
+// or commas and must all match.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' })
+		if len(fields) == 0 {
+			return c, fmt.Errorf("invalid constraint %q", s)
+		}
+
+		var comparators []comparator
+		for _, field := range fields {
+			parsed, err := parseComparator(field)
+			if err != nil {
+				return c, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+		c.ranges = append(c.ranges, comparators)
+	}
+
+	return c, nil
+}
+
+// parseComparator expands a single comparator, including the ^ and ~
+// shorthands and x-ranges, into lower and upper bounds
+func parseComparator(s string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			s = s[len(prefix):]
+			break
+		}
+	}
+	if s == "" {
+		return nil, errors.New("missing version")
+	}
+
+	// Count the significant parts, treating x, X and * as wildcards
+	core, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	parts := strings.Split(core, ".")
+	significant := 0
+	for _, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		significant++
+	}
+	if significant < len(parts) {
+		s = strings.Join(parts[:significant], ".")
+		if significant == 0 {
+			return []comparator{{op: ">=", version: Version{}}}, nil
+		}
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	lower := comparator{op: ">=", version: v}
+
+	// A partial version stands for every version it does not pin down, so
+	// <=1.4 takes in 1.4.9 and >1.4 starts at 1.5.0
+	if significant < 3 && len(v.Prerelease) == 0 {
+		next := Version{Major: v.Major + 1}
+		if significant == 2 {
+			next = Version{Major: v.Major, Minor: v.Minor + 1}
+		}
+		switch op {
+		case "<=":
+			return []comparator{{op: "<", version: next}}, nil
+		case ">":
+			return []comparator{{op: ">=", version: next}}, nil
+		}
+	}
+
+	switch op {
+	case "^":
+		switch {
+		case v.Major > 0 || significant == 1:
+			return []comparator{lower, {op: "<", version: Version{Major: v.Major + 1}}}, nil
+		case v.Minor > 0 || significant == 2:
+			return []comparator{lower, {op: "<", version: Version{Minor: v.Minor + 1}}}, nil
+		default:
+			return []comparator{lower, {op: "<", version: Version{Patch: v.Patch + 1}}}, nil
+		}
+	case "~":
+		if significant == 1 {
+			return []comparator{lower, {op: "<", version: Version{Major: v.Major + 1}}}, nil
+		}
+		return []comparator{lower, {op: "<", version: Version{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	case "", "=":
+		// A partial version matches everything it does not pin down
+		switch significant {
+		case 1:
+			return []comparator{lower, {op: "<", version: Version{Major: v.Major + 1}}}, nil
+		case 2:
+			return []comparator{lower, {op: "<", version: Version{Major: v.Major, Minor: v.Minor + 1}}}, nil
+		}
+		return []comparator{{op: "=", version: v}}, nil
+	}
+
+	return []comparator{{op: op, version: v}}, nil
+}
+
+// Matches reports whether v satisfies the constraint. Pre-releases only
+// match when a comparator of the same range names a pre-release of the same
+// major.minor.patch version, so "^1.4" never selects "1.5.0-rc.1".
+func (c Constraint) Matches(v Version) bool {
+	for _, comparators := range c.ranges {
+		matches := true
+		allowPrerelease := len(v.Prerelease) == 0
+		for _, comp := range comparators {
+			if !comp.matches(v) {
+				matches = false
+				break
+			}
+			cv := comp.version
+			if len(cv.Prerelease) > 0 && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+				allowPrerelease = true
+			}
+		}
+		if matches && allowPrerelease {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the tag with the highest version satisfying the constraint.
+// Tags that are not semantic versions are ignored.
+func Select(tags []string, constraint string) (string, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	best := ""
+	var bestVersion Version
+	for _, tag := range tags {
+		v, err := Parse(tag)
+		if err != nil || !c.Matches(v) {
+			continue
+		}
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("no tag matches %s", constraint)
+	}
+
+	return best, nil
+}
@@ -0,0 +1,170 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "1.4.2", want: "1.4.2"},
+		{in: "v2.0.0", want: "2.0.0"},
+		{in: "1.4", want: "1.4.0"},
+		{in: "3", want: "3.0.0"},
+		{in: "2.0.0-rc.1", want: "2.0.0-rc.1"},
+		{in: "1.0.0+build.5", want: "1.0.0"},
+		{in: " v1.2.3 ", want: "1.2.3"},
+		{in: "", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+		{in: "1.x", wantErr: true},
+		{in: "1.0.0-", wantErr: true},
+		{in: "release", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		v, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want an error", tt.in, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"1.2.0", "1.10.0", -1},
+		{"1.0.10", "1.0.9", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+	}
+
+	for _, tt := range tests {
+		a, _ := Parse(tt.a)
+		b, _ := Parse(tt.b)
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Compare(a); got != -tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestConstraintMatches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"^1.4", []string{"1.4.0", "1.9.9"}, []string{"1.3.9", "2.0.0", "1.5.0-rc.1"}},
+		{"^0.3", []string{"0.3.0", "0.3.7"}, []string{"0.4.0", "0.2.9"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~2.3.0", []string{"2.3.0", "2.3.9"}, []string{"2.4.0", "2.2.9"}},
+		{"~2", []string{"2.0.0", "2.9.0"}, []string{"3.0.0"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.0"}},
+		{"1.4", []string{"1.4.0", "1.4.9"}, []string{"1.5.0", "1.3.0"}},
+		{"=1.4.2", []string{"1.4.2"}, []string{"1.4.3"}},
+		{"*", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc.1"}},
+		{">=1.2 <2", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0"}},
+		{">=1.2, <2", []string{"1.5.0"}, []string{"2.1.0"}},
+		{"^1.0 || ^3.0", []string{"1.2.0", "3.1.0"}, []string{"2.0.0"}},
+
+		// Partial versions cover every version they leave open
+		{"<=1.4", []string{"1.4.0", "1.4.9"}, []string{"1.5.0"}},
+		{"<=1", []string{"1.9.9"}, []string{"2.0.0"}},
+		{">1.4", []string{"1.5.0"}, []string{"1.4.0", "1.4.9"}},
+		{">1", []string{"2.0.0"}, []string{"1.9.9"}},
+		{"<1.4", []string{"1.3.9"}, []string{"1.4.0", "1.4.1"}},
+		{">=1.4", []string{"1.4.0"}, []string{"1.3.9"}},
+		{"<=1.4.2", []string{"1.4.2"}, []string{"1.4.3"}},
+		{">1.4.2", []string{"1.4.3"}, []string{"1.4.2"}},
+
+		// Pre-releases only match a comparator naming one of the same version
+		{">=2.0.0-rc.1", []string{"2.0.0-rc.1", "2.0.0-rc.2", "2.0.0", "2.1.0"}, []string{"2.1.0-rc.1", "2.0.0-beta.1"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) failed: %v", tt.constraint, err)
+			continue
+		}
+		for _, s := range tt.matches {
+			v, _ := Parse(s)
+			if !c.Matches(v) {
+				t.Errorf("%q does not match %s, want a match", tt.constraint, s)
+			}
+		}
+		for _, s := range tt.rejects {
+			v, _ := Parse(s)
+			if c.Matches(v) {
+				t.Errorf("%q matches %s, want no match", tt.constraint, s)
+			}
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", "^", ">=", "1.2.3.4", "^a", "2x", ">=1 ||"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.4.0", "v1.4.3", "v1.10.0", "v2.0.0-rc.1", "v2.0.0", "v2.1.0", "latest", "3.0.0-beta"}
+
+	tests := []struct {
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{constraint: "^1.4", want: "v1.10.0"},
+		{constraint: "~1.4", want: "v1.4.3"},
+		{constraint: "<=1.4", want: "v1.4.3"},
+		{constraint: ">1.4", want: "v2.1.0"},
+		{constraint: "<2", want: "v1.10.0"},
+		{constraint: "2.0", want: "v2.0.0"},
+		{constraint: ">=2.0.0-rc.1 <2.0.0", want: "v2.0.0-rc.1"},
+		{constraint: "*", want: "v2.1.0"},
+		{constraint: ">=3.0.0-beta", want: "3.0.0-beta"},
+		{constraint: "^4", wantErr: true},
+		{constraint: "latest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Select(tags, tt.constraint)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Select(%q) = %s, want an error", tt.constraint, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Select(%q) failed: %v", tt.constraint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Select(%q) = %s, want %s", tt.constraint, got, tt.want)
+		}
+	}
+}
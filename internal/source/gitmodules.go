@@ -0,0 +1,60 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// ParseGitmodules reads a .gitmodules file and returns the URL of each
+// submodule, keyed by its path in the repository
+func ParseGitmodules(data []byte) map[string]string {
+	paths := make(map[string]string)
+	urls := make(map[string]string)
+
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			// [submodule "name"]
+			header := strings.TrimSpace(strings.Trim(line, "[]"))
+			kind, name, _ := strings.Cut(header, " ")
+			section = ""
+			if kind == "submodule" {
+				section = strings.Trim(strings.TrimSpace(name), `"`)
+			}
+			continue
+		}
+
+		if section == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			paths[section] = strings.Trim(value, "/")
+		case "url":
+			urls[section] = value
+		}
+	}
+
+	submodules := make(map[string]string)
+	for name, submodulePath := range paths {
+		if submoduleURL, ok := urls[name]; ok {
+			submodules[submodulePath] = submoduleURL
+		}
+	}
+
+	return submodules
+}
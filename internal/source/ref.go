@@ -0,0 +1,48 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// shaPattern matches full and abbreviated commit SHAs
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// SplitRefPath splits refPath, a ref followed by an optional directory path,
+// at the right place. Branch and tag names may contain slashes, so the path is
+// matched against the repository's refs and the longest match wins. When no
+// ref matches, a leading full or abbreviated commit SHA is accepted.
+func SplitRefPath(p Provider, owner, repo, refPath string) (ref, dirPath string, err error) {
+	refPath = strings.Trim(refPath, "/")
+	first, rest, nested := strings.Cut(refPath, "/")
+
+	// A single segment can only be the ref itself
+	if !nested {
+		return refPath, "", nil
+	}
+
+	names, err := p.ListRefs(owner, repo, first)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, name := range names {
+		if len(name) <= len(ref) {
+			continue
+		}
+		if refPath == name || strings.HasPrefix(refPath, name+"/") {
+			ref = name
+		}
+	}
+
+	if ref != "" {
+		return ref, strings.TrimPrefix(strings.TrimPrefix(refPath, ref), "/"), nil
+	}
+
+	if shaPattern.MatchString(first) {
+		return first, rest, nil
+	}
+
+	return "", "", fmt.Errorf("no branch, tag or commit matches %s", refPath)
+}
@@ -0,0 +1,181 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/liagha/gitdig/internal/bitbucket"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/gitea"
+	"github.com/liagha/gitdig/internal/github"
+	"github.com/liagha/gitdig/internal/gitlab"
+	"github.com/liagha/gitdig/internal/lfs"
+	"github.com/liagha/gitdig/internal/resume"
+)
+
+// Provider is a code hosting service that repositories can be downloaded from
+type Provider interface {
+	// Name returns the name of the hosting service
+	Name() string
+	// ListDirectory lists the direct children of dirPath at ref
+	ListDirectory(owner, repo, ref, dirPath string) ([]forge.Content, error)
+	// Stat describes the file or directory at path. Directories are returned
+	// with type "dir" and no further details.
+	Stat(owner, repo, ref, path string) (forge.Content, error)
+	// LFSEndpoint returns the Git LFS server of a repository
+	LFSEndpoint(owner, repo string) lfs.Endpoint
+	// OpenFile starts downloading the content of a file returned by a
+	// listing, resuming an earlier download when from is set. The caller
+	// reads the content from the returned body and closes it.
+	OpenFile(content forge.Content, from resume.Point) (*resume.Response, error)
+	// ResolveRef resolves a branch, tag or commit to a full commit SHA
+	ResolveRef(owner, repo, ref string) (string, error)
+	// CommitTimes returns the time of the last commit at ref that changed
+	// each of paths. An empty path stands for the whole repository, i.e. the
+	// commit ref points at. Paths without history are left out.
+	CommitTimes(owner, repo, ref string, paths []string) (map[string]time.Time, error)
+	// DefaultBranch returns the name of the repository's default branch
+	DefaultBranch(owner, repo string) (string, error)
+	// ListRefs lists the names of branches and tags starting with prefix.
+	// Providers may return additional names, callers must filter them.
+	ListRefs(owner, repo, prefix string) ([]string, error)
+	// ListTags lists the names of all tags
+	ListTags(owner, repo string) ([]string, error)
+	// ListRepositories lists the repositories of a user, group or organization
+	ListRepositories(owner string) ([]forge.Repository, error)
+}
+
+// TreeLister is implemented by providers that can list a whole directory
+// tree with fewer requests than walking it one directory at a time
+type TreeLister interface {
+	ListTree(owner, repo, ref, dirPath string, recursive bool) ([]forge.Content, error)
+}
+
+// ArchiveOpener is implemented by providers that serve a repository as a
+// gzipped tarball, which fetches any number of files with one request
+type ArchiveOpener interface {
+	// OpenArchive starts downloading a tarball of the repository at ref.
+	// Entries lie below a single top-level directory and keep their path in
+	// the repository. Providers may leave out everything outside dirPath.
+	OpenArchive(owner, repo, ref, dirPath string) (io.ReadCloser, error)
+}
+
+// ReadFile downloads the whole content of a small file, such as a symlink or
+// .gitmodules, into memory
+func ReadFile(provider Provider, content forge.Content) (data []byte, err error) {
+	resp, err := provider.OpenFile(content, resume.Point{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close response body: %w", cerr)
+		}
+	}()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return data, nil
+}
+
+// tokenEnv maps each provider to the environment variable holding its token
+var tokenEnv = map[string]string{
+	forge.ProviderGitHub: "GITHUB_TOKEN",
+	forge.ProviderGitLab: "GITLAB_TOKEN",
+	forge.ProviderGitea:  "GITEA_TOKEN",
+
+	forge.ProviderBitbucket:       "BITBUCKET_TOKEN",
+	forge.ProviderBitbucketServer: "BITBUCKET_TOKEN",
+}
+
+// hostEnv maps each provider other than GitHub to the environment variable
+// naming the self-hosted instances its token may be sent to
+var hostEnv = map[string]string{
+	forge.ProviderGitLab: "GITLAB_HOST",
+	forge.ProviderGitea:  "GITEA_HOST",
+
+	forge.ProviderBitbucket:       "BITBUCKET_HOST",
+	forge.ProviderBitbucketServer: "BITBUCKET_HOST",
+}
+
+// publicAPIURL maps each provider to the API of its public instance
+var publicAPIURL = map[string]string{
+	forge.ProviderGitLab:    gitlab.PublicAPIURL,
+	forge.ProviderGitea:     gitea.CodebergAPIURL,
+	forge.ProviderBitbucket: bitbucket.CloudAPIURL,
+}
+
+// Token returns the token to send to target. token, given with -token or in
+// the config file, is only sent to home, the GitHub host it was configured
+// for with -api-url or api_url, github.com by default. Other targets get the
+// token from their provider's environment variable, e.g. GITLAB_TOKEN, and
+// GITHUB_TOKEN is only read for github.com and home, so that a URL on an
+// unknown host, which is taken for GitHub Enterprise, does not receive it.
+// Likewise GITLAB_TOKEN, GITEA_TOKEN and BITBUCKET_TOKEN are only sent to
+// gitlab.com, codeberg.org and bitbucket.org, or to the hosts listed in
+// GITLAB_HOST, GITEA_HOST and BITBUCKET_HOST.
+func Token(target forge.DownloadTarget, token string, home forge.Host) string {
+	apiURL := strings.TrimSuffix(target.Host.APIURL, "/")
+
+	if target.Provider != forge.ProviderGitHub && target.Provider != "" {
+		if apiURL == publicAPIURL[target.Provider] || configuredHost(apiURL, os.Getenv(hostEnv[target.Provider])) {
+			return os.Getenv(tokenEnv[target.Provider])
+		}
+		return ""
+	}
+
+	if apiURL == strings.TrimSuffix(home.APIURL, "/") {
+		if token != "" {
+			return token
+		}
+		return os.Getenv(tokenEnv[forge.ProviderGitHub])
+	}
+	if apiURL == github.PublicHost.APIURL {
+		return os.Getenv(tokenEnv[forge.ProviderGitHub])
+	}
+	return ""
+}
+
+// configuredHost reports whether the API at apiURL is served by one of the
+// comma separated host names in hosts. A name with a port only matches that
+// port.
+func configuredHost(apiURL, hosts string) bool {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host != "" && (strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname())) {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates the provider serving target, authenticating with token, which
+// may be empty. Use Token to pick the token for target.
+func New(target forge.DownloadTarget, token string) (Provider, error) {
+	switch target.Provider {
+	case forge.ProviderGitHub, "":
+		return github.NewClient(target.Host, token), nil
+	case forge.ProviderGitLab:
+		return gitlab.NewClient(target.Host.APIURL, token), nil
+	case forge.ProviderGitea:
+		return gitea.NewClient(target.Host.APIURL, token), nil
+	case forge.ProviderBitbucket:
+		return bitbucket.NewClient(target.Host.APIURL, token), nil
+	case forge.ProviderBitbucketServer:
+		return bitbucket.NewServerClient(target.Host.APIURL, token), nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", target.Provider)
+	}
+}
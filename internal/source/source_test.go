@@ -0,0 +1,55 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/github"
+)
+
+func TestToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "github-env")
+	t.Setenv("GITLAB_TOKEN", "gitlab-env")
+	t.Setenv("GITEA_TOKEN", "gitea-env")
+	t.Setenv("BITBUCKET_TOKEN", "bitbucket-env")
+	t.Setenv("GITLAB_HOST", "gitlab.example.com")
+	t.Setenv("GITEA_HOST", "")
+	t.Setenv("BITBUCKET_HOST", " git.example.com:7990 , other.example ")
+
+	enterprise := forge.Host{APIURL: "https://ghe.example.com/api/v3"}
+
+	tests := []struct {
+		name     string
+		provider string
+		apiURL   string
+		token    string
+		home     forge.Host
+		want     string
+	}{
+		{name: "flag token on github.com", provider: forge.ProviderGitHub, apiURL: github.PublicHost.APIURL, token: "flag", home: github.PublicHost, want: "flag"},
+		{name: "env token on github.com", provider: forge.ProviderGitHub, apiURL: github.PublicHost.APIURL, home: github.PublicHost, want: "github-env"},
+		{name: "flag token on the configured server", provider: forge.ProviderGitHub, apiURL: enterprise.APIURL, token: "flag", home: enterprise, want: "flag"},
+		{name: "env token on github.com with a server configured", provider: forge.ProviderGitHub, apiURL: github.PublicHost.APIURL, token: "flag", home: enterprise, want: "github-env"},
+		{name: "unknown host taken for GitHub Enterprise", provider: forge.ProviderGitHub, apiURL: "https://evil.example/api/v3", token: "flag", home: github.PublicHost, want: ""},
+
+		{name: "gitlab.com", provider: forge.ProviderGitLab, apiURL: "https://gitlab.com/api/v4", token: "flag", home: github.PublicHost, want: "gitlab-env"},
+		{name: "listed GitLab instance", provider: forge.ProviderGitLab, apiURL: "https://gitlab.example.com/api/v4", want: "gitlab-env"},
+		{name: "unlisted GitLab instance", provider: forge.ProviderGitLab, apiURL: "https://gitlab.evil.example/api/v4", want: ""},
+
+		{name: "codeberg.org", provider: forge.ProviderGitea, apiURL: "https://codeberg.org/api/v1", want: "gitea-env"},
+		{name: "unlisted Gitea instance", provider: forge.ProviderGitea, apiURL: "https://gitea.example.com/api/v1", want: ""},
+
+		{name: "bitbucket.org", provider: forge.ProviderBitbucket, apiURL: "https://api.bitbucket.org/2.0", want: "bitbucket-env"},
+		{name: "listed Bitbucket Server with port", provider: forge.ProviderBitbucketServer, apiURL: "https://git.example.com:7990/rest/api/1.0", want: "bitbucket-env"},
+		{name: "listed Bitbucket Server on another port", provider: forge.ProviderBitbucketServer, apiURL: "https://git.example.com:8443/rest/api/1.0", want: ""},
+		{name: "listed Bitbucket Server without port", provider: forge.ProviderBitbucketServer, apiURL: "https://other.example/bitbucket/rest/api/1.0", want: "bitbucket-env"},
+		{name: "unlisted Bitbucket Server", provider: forge.ProviderBitbucketServer, apiURL: "https://evil.example/rest/api/1.0", want: ""},
+	}
+
+	for _, tt := range tests {
+		target := forge.DownloadTarget{Provider: tt.provider, Host: forge.Host{APIURL: tt.apiURL}}
+		if got := Token(target, tt.token, tt.home); got != tt.want {
+			t.Errorf("%s: Token = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/liagha/gitdig/internal/bitbucket"
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/gitea"
+	"github.com/liagha/gitdig/internal/github"
+	"github.com/liagha/gitdig/internal/gitlab"
+	"github.com/liagha/gitdig/internal/semver"
+)
+
+// ParsePath parses a repository URL or owner/repo/path shorthand into a
+// download target. Shorthand paths are resolved against defaultHost. A trailing
+// @constraint such as owner/repo/path@^1.4 selects the newest matching tag.
+func ParsePath(path string, defaultHost forge.Host) (target forge.DownloadTarget, err error) {
+	path, constraint := splitConstraint(path)
+	if constraint == "" && hasOperatorConstraint(path) {
+		return forge.DownloadTarget{}, errors.New("@constraint only works with the owner/repo[/path]@constraint shorthand, not with URLs or refs given with tree/ or blob/")
+	}
+
+	target, err = parsePath(path, defaultHost)
+	if err != nil {
+		return forge.DownloadTarget{}, err
+	}
+	target.Constraint = constraint
+
+	return target, nil
+}
+
+// splitConstraint splits a trailing @constraint off a shorthand path. URLs
+// and paths naming a ref with tree/ or blob/ never carry one, and a suffix
+// that is not a valid constraint, as in docs/logo@2x.png, is part of the path.
+func splitConstraint(path string) (string, string) {
+	i := strings.LastIndex(path, "@")
+	if i <= 0 || !strings.Contains(path[:i], "/") {
+		return path, ""
+	}
+
+	if normalized, err := normalizeTarget(path); err != nil || strings.Contains(normalized, "://") {
+		return path, ""
+	}
+	if parts := strings.Split(strings.Trim(path, "/"), "/"); len(parts) > 2 && (parts[2] == "tree" || parts[2] == "blob") {
+		return path, ""
+	}
+
+	constraint := path[i+1:]
+	if constraint == "" || strings.Contains(constraint, "/") {
+		return path, ""
+	}
+	if _, err := semver.ParseConstraint(constraint); err != nil {
+		return path, ""
+	}
+
+	return path[:i], constraint
+}
+
+// hasOperatorConstraint reports whether path ends in an @constraint using an
+// operator such as ^, ~ or >=. A ref or file name is unlikely to end like
+// that, so it is a constraint splitConstraint did not accept.
+func hasOperatorConstraint(path string) bool {
+	i := strings.LastIndex(path, "@")
+	if i < 0 {
+		return false
+	}
+
+	constraint := path[i+1:]
+	if strings.Contains(constraint, "/") || !strings.ContainsAny(constraint, "^~<>=*| ") {
+		return false
+	}
+
+	_, err := semver.ParseConstraint(constraint)
+	return err == nil
+}
+
+// scpPattern matches the scp-like SSH form user@host:path
+var scpPattern = regexp.MustCompile(`^[\w.-]+@([^:/]+):/?(.+)$`)
+
+// hostPortPattern matches a scheme-less reference starting with host:port,
+// as in localhost:8080/owner/repo
+var hostPortPattern = regexp.MustCompile(`^([\w.-]+):(\d+)(/|$)`)
+
+// isLoopback reports whether host names the local machine, which is served
+// over plain http
+func isLoopback(host string) bool {
+	return host == "localhost" || strings.HasPrefix(host, "127.")
+}
+
+// normalizeTarget rewrites the SSH, git:// and scheme-less forms of a
+// repository reference into the equivalent https URL. Shorthand paths are
+// returned unchanged.
+func normalizeTarget(path string) (string, error) {
+	if scheme, _, ok := strings.Cut(path, "://"); ok {
+		u, err := url.Parse(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid URL: %w", err)
+		}
+
+		switch strings.ToLower(scheme) {
+		case "http", "https":
+			return path, nil
+		case "ssh", "git", "git+ssh":
+			// The SSH port says nothing about where the web interface is served
+			u.Scheme = "https"
+			u.User = nil
+			u.Host = u.Hostname()
+			return u.String(), nil
+		}
+
+		return "", fmt.Errorf("unsupported URL scheme %q, %s", scheme, forge.TargetForms)
+	}
+
+	if m := hostPortPattern.FindStringSubmatch(path); m != nil {
+		if isLoopback(m[1]) {
+			return "http://" + path, nil
+		}
+		return "https://" + path, nil
+	}
+
+	if m := scpPattern.FindStringSubmatch(path); m != nil {
+		return "https://" + m[1] + "/" + m[2], nil
+	}
+
+	// A first segment that looks like a host name, as in github.com/owner/repo
+	if host, _, _ := strings.Cut(path, "/"); strings.ContainsAny(host, ".:") {
+		return "https://" + path, nil
+	}
+
+	return path, nil
+}
+
+func parsePath(path string, defaultHost forge.Host) (forge.DownloadTarget, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return forge.DownloadTarget{}, fmt.Errorf("empty target, %s", forge.TargetForms)
+	}
+
+	hostPort := hostPortPattern.FindStringSubmatch(path)
+
+	path, err := normalizeTarget(path)
+	if err != nil {
+		return forge.DownloadTarget{}, err
+	}
+
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		target, err := parseURL(path, defaultHost)
+		if err != nil {
+			return forge.DownloadTarget{}, err
+		}
+
+		// A bare host:port is usually a local test server rather than
+		// GitHub Enterprise, which is only assumed when configured
+		if hostPort != nil && target.Provider == forge.ProviderGitHub && target.Host != defaultHost {
+			host := hostPort[1] + ":" + hostPort[2]
+			return forge.DownloadTarget{}, fmt.Errorf("cannot tell which service runs at %s; give its full URL, or set -api-url to use it as a GitHub Enterprise server", host)
+		}
+
+		return target, nil
+	}
+
+	return github.ParseShorthand(path, defaultHost)
+}
+
+// parseURL hands a URL to the parser of the hosting service it points at.
+// URLs on hosts no other service claims are taken for GitHub Enterprise.
+func parseURL(rawURL string, defaultHost forge.Host) (forge.DownloadTarget, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return forge.DownloadTarget{}, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if parsedURL.Host == "" {
+		return forge.DownloadTarget{}, fmt.Errorf("missing host in URL, %s", forge.TargetForms)
+	}
+
+	if parsedURL.Host != "raw.githubusercontent.com" && github.HostFor(parsedURL.Host) != github.PublicHost {
+		switch {
+		case gitlab.IsURL(parsedURL):
+			return gitlab.ParseURL(parsedURL)
+		case bitbucket.IsCloudURL(parsedURL):
+			return bitbucket.ParseCloudURL(parsedURL)
+		case gitea.IsURL(parsedURL):
+			return gitea.ParseURL(parsedURL)
+		case bitbucket.IsServerURL(parsedURL):
+			return bitbucket.ParseServerURL(parsedURL)
+		}
+	}
+
+	return github.ParseURL(parsedURL, defaultHost)
+}
+
+// ParseSubmoduleURL turns the URL of a submodule of parent, as written in
+// .gitmodules, into a download target. Relative URLs such as ../other.git and
+// URLs on the parent's host keep the parent's provider and endpoints; any
+// other URL is parsed like a target given on the command line. home is the
+// configured GitHub host. A URL on a host that is neither github.com, home nor
+// recognisable as another service is refused rather than taken for GitHub
+// Enterprise, so that arbitrary hosts named in .gitmodules are not contacted.
+func ParseSubmoduleURL(parent forge.DownloadTarget, submoduleURL string, home forge.Host) (forge.DownloadTarget, error) {
+	target := forge.DownloadTarget{Provider: parent.Provider, Host: parent.Host}
+
+	var repoPath string
+	if strings.HasPrefix(submoduleURL, "./") || strings.HasPrefix(submoduleURL, "../") {
+		repoPath = path.Join(parent.Owner, parent.Repo, submoduleURL)
+	} else {
+		normalized, err := normalizeTarget(submoduleURL)
+		if err != nil {
+			return forge.DownloadTarget{}, err
+		}
+
+		u, err := url.Parse(normalized)
+		if err != nil || u.Host == "" {
+			return forge.DownloadTarget{}, fmt.Errorf("unsupported submodule URL %q", submoduleURL)
+		}
+
+		if !sameHost(u.Hostname(), parent.Host.APIURL) {
+			target, err := ParsePath(normalized, home)
+			if err != nil {
+				return forge.DownloadTarget{}, err
+			}
+			if target.Provider == forge.ProviderGitHub && target.Host != home && target.Host != github.PublicHost {
+				return forge.DownloadTarget{}, fmt.Errorf("unknown host %s, only github.com, the configured GitHub server and GitLab, Gitea and Bitbucket URLs are followed", u.Host)
+			}
+			return target, nil
+		}
+
+		// Bitbucket Server serves its clone URLs below /scm
+		repoPath = strings.TrimPrefix(strings.Trim(u.Path, "/"), "scm/")
+	}
+
+	i := strings.LastIndex(repoPath, "/")
+	if i <= 0 || strings.HasPrefix(repoPath, "..") {
+		return forge.DownloadTarget{}, fmt.Errorf("submodule URL %q does not name a repository", submoduleURL)
+	}
+	target.Owner = repoPath[:i]
+	target.Repo = strings.TrimSuffix(repoPath[i+1:], ".git")
+
+	return target, nil
+}
+
+// sameHost reports whether hostname serves the API at apiURL
+func sameHost(hostname, apiURL string) bool {
+	u, err := url.Parse(apiURL)
+	return err == nil && u.Hostname() == hostname
+}
+
+// ReadTargetsFromFile reads a list of repository paths from a file
+func ReadTargetsFromFile(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open list file: %w", err)
+	}
+	defer file.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			targets = append(targets, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading list file: %w", err)
+	}
+
+	return targets, nil
+}
+
+// ParseTargets parses multiple repository paths and converts them to download targets.
+// Shorthand paths are resolved against defaultHost.
+func ParseTargets(paths []string, baseDir string, defaultHost forge.Host) ([]forge.DownloadTarget, error) {
+	var targets []forge.DownloadTarget
+
+	for _, path := range paths {
+		target, err := ParsePath(path, defaultHost)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path '%s': %w", path, err)
+		}
+
+		repo, dirPath := target.Repo, target.DirPath
+
+		// Create local directory path
+		localDir := baseDir
+		if localDir == "" {
+			localDir = repo
+			if dirPath != "" {
+				localDir = fmt.Sprintf("%s-%s", repo, strings.ReplaceAll(dirPath, "/", "-"))
+			}
+		} else if len(paths) > 1 {
+			// When downloading multiple targets to the same base directory,
+			// create subdirectories for each target
+			subDir := repo
+			if dirPath != "" {
+				subDir = fmt.Sprintf("%s-%s", repo, strings.ReplaceAll(dirPath, "/", "-"))
+			}
+			localDir = fmt.Sprintf("%s/%s", baseDir, subDir)
+		}
+
+		target.LocalDir = localDir
+		target.OutputDir = baseDir
+		target.Source = path
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
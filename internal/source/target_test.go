@@ -0,0 +1,144 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liagha/gitdig/internal/forge"
+	"github.com/liagha/gitdig/internal/github"
+)
+
+func TestParsePathConstraint(t *testing.T) {
+	tests := []struct {
+		in         string
+		repo       string
+		dirPath    string
+		refPath    string
+		constraint string
+		wantErr    bool
+	}{
+		{in: "owner/repo@^1.4", repo: "repo", constraint: "^1.4"},
+		{in: "owner/repo/proto@~2", repo: "repo", dirPath: "proto", constraint: "~2"},
+		{in: "owner/repo/docs/logo@2x.png", repo: "repo", dirPath: "docs/logo@2x.png"},
+		{in: "owner/repo/tree/release@1.4", repo: "repo", refPath: "release@1.4"},
+		{in: "owner/repo/tree/main@^1", wantErr: true},
+		{in: "owner/repo/blob/main/go.mod@>=1.2", wantErr: true},
+		{in: "https://github.com/owner/repo/tree/main/src@~1.2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		target, err := ParsePath(tt.in, github.PublicHost)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePath(%q) = %+v, want an error", tt.in, target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePath(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if target.Repo != tt.repo || target.DirPath != tt.dirPath || target.RefPath != tt.refPath || target.Constraint != tt.constraint {
+			t.Errorf("ParsePath(%q) = repo %q, dir %q, ref path %q, constraint %q, want %q, %q, %q, %q",
+				tt.in, target.Repo, target.DirPath, target.RefPath, target.Constraint, tt.repo, tt.dirPath, tt.refPath, tt.constraint)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	local := forge.Host{APIURL: "http://localhost:8080/api/v3", RawURL: "http://localhost:8080/raw"}
+
+	tests := []struct {
+		in      string
+		host    forge.Host
+		wantErr string
+	}{
+		{in: "owner", wantErr: `missing repository name after "owner", expected owner/REPO`},
+		{in: "owner/", wantErr: `missing repository name after "owner"`},
+		{in: "docs/logo@2x.png", wantErr: `invalid owner or repository name "logo@2x.png"`},
+		{in: "docs/logo.png", wantErr: "looks like a file path"},
+		{in: "localhost:8080/owner/repo", wantErr: "cannot tell which service runs at localhost:8080"},
+		{in: "localhost:8080/owner/repo", host: local},
+		{in: "owner/next.js"},
+		{in: "owner/repo/docs/logo.png"},
+	}
+
+	for _, tt := range tests {
+		host := tt.host
+		if host.APIURL == "" {
+			host = github.PublicHost
+		}
+
+		target, err := ParsePath(tt.in, host)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ParsePath(%q) failed: %v", tt.in, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParsePath(%q) = %+v, %v, want an error containing %q", tt.in, target, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePathHostPort(t *testing.T) {
+	local := forge.Host{APIURL: "http://localhost:8080/api/v3", RawURL: "http://localhost:8080/raw"}
+
+	target, err := ParsePath("localhost:8080/owner/repo/tree/main/docs", local)
+	if err != nil {
+		t.Fatalf("ParsePath failed: %v", err)
+	}
+	if target.Host != local || target.Owner != "owner" || target.Repo != "repo" || target.RefPath != "main/docs" {
+		t.Errorf("ParsePath = %+v, want owner/repo at main/docs on the configured host", target)
+	}
+
+	target, err = ParsePath("localhost:8080/group/project/-/tree/main", github.PublicHost)
+	if err != nil {
+		t.Fatalf("ParsePath failed: %v", err)
+	}
+	if target.Provider != forge.ProviderGitLab || target.Host.APIURL != "http://localhost:8080/api/v4" {
+		t.Errorf("ParsePath = %+v, want GitLab at http://localhost:8080/api/v4", target)
+	}
+}
+
+func TestParseSubmoduleURL(t *testing.T) {
+	home := forge.Host{APIURL: "https://ghe.example.com/api/v3", RawURL: "https://ghe.example.com/raw"}
+	parent := forge.DownloadTarget{Provider: forge.ProviderGitHub, Host: home, Owner: "team", Repo: "app"}
+
+	tests := []struct {
+		url      string
+		provider string
+		apiURL   string
+		owner    string
+		repo     string
+		wantErr  bool
+	}{
+		{url: "../lib.git", provider: forge.ProviderGitHub, apiURL: home.APIURL, owner: "team", repo: "lib"},
+		{url: "git@ghe.example.com:other/lib.git", provider: forge.ProviderGitHub, apiURL: home.APIURL, owner: "other", repo: "lib"},
+		{url: "https://github.com/golang/go.git", provider: forge.ProviderGitHub, apiURL: github.PublicHost.APIURL, owner: "golang", repo: "go"},
+		{url: "https://gitlab.com/group/sub/project.git", provider: forge.ProviderGitLab, apiURL: "https://gitlab.com/api/v4", owner: "group/sub", repo: "project"},
+		{url: "https://bitbucket.org/workspace/repo.git", provider: forge.ProviderBitbucket, apiURL: "https://api.bitbucket.org/2.0", owner: "workspace", repo: "repo"},
+		{url: "https://git.evil.example/owner/repo.git", wantErr: true},
+		{url: "git@evil.example:owner/repo.git", wantErr: true},
+		{url: "../../../outside", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		target, err := ParseSubmoduleURL(parent, tt.url, home)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSubmoduleURL(%q) = %+v, want an error", tt.url, target)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSubmoduleURL(%q) failed: %v", tt.url, err)
+			continue
+		}
+		if target.Provider != tt.provider || target.Host.APIURL != tt.apiURL || target.Owner != tt.owner || target.Repo != tt.repo {
+			t.Errorf("ParseSubmoduleURL(%q) = %s %s %s/%s, want %s %s %s/%s", tt.url,
+				target.Provider, target.Host.APIURL, target.Owner, target.Repo, tt.provider, tt.apiURL, tt.owner, tt.repo)
+		}
+	}
+}
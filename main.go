@@ -4,31 +4,27 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/liagha/gitdig/internal/config"
 	"github.com/liagha/gitdig/internal/display"
 	"github.com/liagha/gitdig/internal/downloader"
+	"github.com/liagha/gitdig/internal/forge"
 	"github.com/liagha/gitdig/internal/github"
+	"github.com/liagha/gitdig/internal/glob"
+	"github.com/liagha/gitdig/internal/source"
 )
 
-func browseRepositories(user string, token string) (string, error) {
+func browseRepositories(provider source.Provider, user string) (string, error) {
 	display.Bold("Fetching repositories for %s...\n", user)
 
-	var repos []github.Repository
-	var err error
-
-	// Try as organization first
-	repos, err = github.GetRepositoriesForOrg(user, token)
+	repos, err := provider.ListRepositories(user)
 	if err != nil {
-		display.Info("Trying as user...\n")
-		// Try as user
-		repos, err = github.GetRepositoriesForUser(user, token)
-		if err != nil {
-			return "", fmt.Errorf("failed to get repositories: %w", err)
-		}
+		return "", fmt.Errorf("failed to get repositories: %w", err)
 	}
 
 	if len(repos) == 0 {
@@ -79,25 +75,75 @@ func main() {
 	flag.StringVar(&flags.URL, "u", "", "GitHub repository URL or path (can be specified multiple times)")
 	flag.StringVar(&flags.Token, "token", "", "GitHub API token for authentication")
 	flag.StringVar(&flags.Output, "o", "", "Output directory")
+	flag.StringVar(&flags.APIURL, "api-url", "", "GitHub API base URL, e.g. https://ghe.example.com/api/v3 (default https://api.github.com)")
+	flag.StringVar(&flags.RawURL, "raw-url", "", "Base URL for raw file downloads, e.g. https://ghe.example.com/raw")
+	flag.StringVar(&flags.Traversal, "traversal", downloader.TraversalAuto, "Directory listing mode: contents (one request per directory), tree (one Git Trees API request) or auto (tree where supported)")
 	flag.BoolVar(&flags.Recursive, "r", true, "Download directories recursively")
 	flag.IntVar(&flags.Concurrency, "c", 5, "Number of concurrent downloads")
 	flag.BoolVar(&flags.Verbose, "v", false, "Verbose output")
-	flag.BoolVar(&flags.ZipOutput, "zip", false, "Create ZIP archive instead of extracting files")
+	flag.BoolVar(&flags.ZipOutput, "zip", false, "Create ZIP archive instead of extracting files (same as -format zip)")
+	flag.StringVar(&flags.Format, "format", "", "Save to an archive instead of extracting files: zip, tar, tar.gz or tar.zst (default: taken from the -o extension, if any)")
 	flag.BoolVar(&flags.Preview, "preview", false, "Preview what would be downloaded without downloading")
-	flag.BoolVar(&flags.Update, "update", false, "Only download new or changed files")
+	flag.BoolVar(&flags.Update, "update", false, "Only download new or changed files, comparing git blob hashes")
 	flag.StringVar(&flags.ListFile, "list", "", "File containing list of repositories to download")
 	flag.IntVar(&flags.Retries, "retries", 3, "Number of retries for failed downloads")
 	flag.StringVar(&flags.User, "user", "", "GitHub username or organization for interactive repository selection")
 	flag.BoolVar(&flags.Interactive, "i", false, "Interactive mode for selecting repositories")
+	flag.Var(&flags.Include, "include", "Only download paths matching this glob, e.g. **/*.proto (can be repeated)")
+	flag.Var(&flags.Exclude, "exclude", "Skip paths matching this glob, e.g. testdata/** (can be repeated)")
+	flag.BoolVar(&flags.KeepLFSPointers, "keep-lfs-pointers", false, "Save Git LFS pointer files instead of downloading the objects they point to")
+	flag.StringVar(&flags.Submodules, "submodules", downloader.SubmodulesWarn, "Submodule handling: skip, warn or recurse (download each submodule at its pinned commit)")
+	flag.BoolVar(&flags.Dereference, "dereference", false, "Save the files and directories symlinks point to instead of the links")
+	flag.StringVar(&flags.Mtime, "mtime", downloader.TimestampsNow, "File modification times: now, commit (date of the downloaded commit) or file (last commit that changed each file)")
+	flag.BoolVar(&flags.Lock, "lock", false, "Record the downloaded commit and files in a .gitdig.lock file next to the output")
+	flag.BoolVar(&flags.Locked, "locked", false, "Download the commit recorded in .gitdig.lock, failing if the upstream files no longer match it")
+	flag.BoolVar(&flags.Sync, "sync", false, "Mirror the remote directory: update changed files and delete local files removed upstream")
+	flag.BoolVar(&flags.Yes, "yes", false, "Delete files in -sync mode without asking for confirmation")
+	flag.StringVar(&flags.Strategy, "strategy", downloader.StrategyFiles, "How to fetch the files of a directory: files (one request each), archive (one tarball of the repository) or auto (archive for large downloads)")
 
 	flag.Parse()
 
 	// Display banner
 	display.BoldCyan("\n%s v%s - GitHub Repository Downloader\n\n", config.AppName, config.AppVersion)
 
-	// Check for token environment variable if not provided via flag
+	fileConfig, err := config.LoadFile()
+	if err != nil {
+		display.Error("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Fall back to the config file token. It is only sent to the GitHub host
+	// configured below; other hosts get their provider's environment variable
+	// (GITHUB_TOKEN, GITLAB_TOKEN, ...), see source.Token
 	if flags.Token == "" {
-		flags.Token = os.Getenv("GITHUB_TOKEN")
+		flags.Token = fileConfig.Token
+	}
+
+	// Resolve API endpoints: flag, then environment, then config file
+	host := github.PublicHost
+	if flags.APIURL == "" {
+		flags.APIURL = os.Getenv("GITDIG_API_URL")
+	}
+	if flags.APIURL == "" {
+		flags.APIURL = fileConfig.APIURL
+	}
+	if flags.RawURL == "" {
+		flags.RawURL = os.Getenv("GITDIG_RAW_URL")
+	}
+	if flags.RawURL == "" {
+		flags.RawURL = fileConfig.RawURL
+	}
+	if flags.APIURL != "" {
+		apiURL, err := url.Parse(flags.APIURL)
+		if err != nil || apiURL.Host == "" {
+			display.Error("Error: invalid API URL '%s'\n", flags.APIURL)
+			os.Exit(1)
+		}
+		host = github.HostFor(apiURL.Host)
+		host.APIURL = strings.TrimSuffix(flags.APIURL, "/")
+	}
+	if flags.RawURL != "" {
+		host.RawURL = strings.TrimSuffix(flags.RawURL, "/")
 	}
 
 	// Collect all target URLs/paths
@@ -116,7 +162,7 @@ func main() {
 
 	// If -list flag is provided, read targets from file
 	if flags.ListFile != "" {
-		fileTargets, err := github.ReadTargetsFromFile(flags.ListFile)
+		fileTargets, err := source.ReadTargetsFromFile(flags.ListFile)
 		if err != nil {
 			display.Error("Error: %v\n", err)
 			os.Exit(1)
@@ -135,7 +181,14 @@ func main() {
 			fmt.Scanln(&user)
 		}
 
-		repoPath, err := browseRepositories(user, flags.Token)
+		home := forge.DownloadTarget{Provider: forge.ProviderGitHub, Host: host}
+		provider, err := source.New(home, source.Token(home, flags.Token, host))
+		if err != nil {
+			display.Error("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		repoPath, err := browseRepositories(provider, user)
 		if err != nil {
 			display.Error("Error: %v\n", err)
 			os.Exit(1)
@@ -151,20 +204,93 @@ func main() {
 		os.Exit(1)
 	}
 
+	if flags.Traversal != downloader.TraversalContents && flags.Traversal != downloader.TraversalTree && flags.Traversal != downloader.TraversalAuto {
+		display.Error("Error: invalid traversal mode '%s', must be contents, tree or auto\n", flags.Traversal)
+		os.Exit(1)
+	}
+
+	if flags.Submodules != downloader.SubmodulesSkip && flags.Submodules != downloader.SubmodulesWarn && flags.Submodules != downloader.SubmodulesRecurse {
+		display.Error("Error: invalid submodule policy '%s', must be skip, warn or recurse\n", flags.Submodules)
+		os.Exit(1)
+	}
+
+	if flags.Mtime != downloader.TimestampsNow && flags.Mtime != downloader.TimestampsCommit && flags.Mtime != downloader.TimestampsFile {
+		display.Error("Error: invalid mtime mode '%s', must be now, commit or file\n", flags.Mtime)
+		os.Exit(1)
+	}
+
+	if flags.Strategy != downloader.StrategyFiles && flags.Strategy != downloader.StrategyArchive && flags.Strategy != downloader.StrategyAuto {
+		display.Error("Error: invalid strategy '%s', must be files, archive or auto\n", flags.Strategy)
+		os.Exit(1)
+	}
+
+	// Pick the archive format: -format, then -zip, then the -o extension
+	if flags.ZipOutput {
+		if flags.Format != "" && flags.Format != downloader.FormatZip {
+			display.Error("Error: -zip cannot be combined with -format %s\n", flags.Format)
+			os.Exit(1)
+		}
+		flags.Format = downloader.FormatZip
+	}
+	if flags.Format == "" {
+		flags.Format = downloader.FormatFromPath(flags.Output)
+	}
+	if flags.Format != "" && !slices.Contains(downloader.Formats, flags.Format) {
+		display.Error("Error: invalid archive format '%s', must be zip, tar, tar.gz or tar.zst\n", flags.Format)
+		os.Exit(1)
+	}
+	archiveOutput := flags.Format != ""
+
+	if flags.Sync && archiveOutput {
+		display.Error("Error: -sync cannot be combined with archive output\n")
+		os.Exit(1)
+	}
+	if flags.Sync && !flags.Recursive {
+		display.Error("Error: -sync cannot be combined with -r=false, which leaves subdirectories unlisted\n")
+		os.Exit(1)
+	}
+
+	// Mirroring only fetches what changed
+	if flags.Sync {
+		flags.Update = true
+	}
+
+	for _, pattern := range append(flags.Include, flags.Exclude...) {
+		if err := glob.Validate(pattern); err != nil {
+			display.Error("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Create downloader
 	dl := downloader.New(
 		flags.Token,
+		flags.Traversal,
 		flags.Recursive,
 		flags.Concurrency,
 		flags.Verbose,
-		flags.ZipOutput,
+		archiveOutput,
 		flags.Preview,
 		flags.Update,
 		flags.Retries,
 	)
+	dl.TokenHost = host
+	dl.Filter = glob.Filter{Include: flags.Include, Exclude: flags.Exclude}
+	dl.KeepLFSPointers = flags.KeepLFSPointers
+	dl.Submodules = flags.Submodules
+	dl.Dereference = flags.Dereference
+	dl.Timestamps = flags.Mtime
+	dl.WriteLock = flags.Lock
+	dl.Locked = flags.Locked
+	dl.Sync = flags.Sync
+	dl.AssumeYes = flags.Yes
+	dl.Strategy = flags.Strategy
+	if archiveOutput {
+		dl.Format = flags.Format
+	}
 
 	// Process targets
-	downloadTargets, err := github.ParseTargets(targets, flags.Output)
+	downloadTargets, err := source.ParseTargets(targets, flags.Output, host)
 	if err != nil {
 		display.Error("Error: %v\n", err)
 		os.Exit(1)
@@ -176,19 +302,14 @@ func main() {
 			display.BoldCyan("\nProcessing next target (%d/%d)...\n", i+1, len(downloadTargets))
 		}
 
-		// For ZIP output with multiple targets, add target identifier to filename
+		// For archive output with multiple targets, add target identifier to filename
 		localDir := target.LocalDir
-		if flags.ZipOutput && !strings.HasSuffix(localDir, ".zip") {
-			localDir += ".zip"
+		if archiveOutput {
+			localDir = downloader.ArchivePath(localDir, flags.Format)
 		}
 
-		err := dl.DownloadRepository(
-			target.Owner,
-			target.Repo,
-			target.Branch,
-			target.DirPath,
-			localDir,
-		)
+		target.LocalDir = localDir
+		err := dl.DownloadRepository(target)
 
 		if err != nil {
 			display.Error("Error: %v\n", err)